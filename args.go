@@ -0,0 +1,224 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"encoding"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ArgsConfig controls the arguments returned by Options.Args.
+type ArgsConfig struct {
+	// OmitDefaults leaves out the options that were not given,
+	// including those that were only implied by other options.
+	// Otherwise the values of options that were not given are included
+	// as well, if they are not zero values, unless the options have
+	// Requires or Conflicts relations, as giving those could change
+	// the outcome.
+	OmitDefaults bool
+
+	// Redact replaces the values of Secret options with "<redacted>",
+	// except for those read from files, whose names are kept.
+	// The arguments can then be logged, but not parsed again.
+	Redact bool
+}
+
+// Args returns arguments that give the current values of the options,
+// for example to run another copy of the program with the same
+// configuration.  The options are in the order they were added, using
+// long names where possible, with values attached (as in --name=value).
+// Nothing is quoted.  Options given several times, such as slices and
+// maps, are given once for each value.
+//
+// Parsing the arguments, with ArgP targets that hold their initial
+// values (so empty slices and maps), gives the same values again.
+func (o *Options) Args(cfg ArgsConfig) []string {
+	args := []string{}
+	for _, opt := range o.allOpts {
+		given := opt.Seen && opt.Source.Kind != SourceImplied
+		if !given && (cfg.OmitDefaults || len(opt.Requires) > 0 ||
+			o.hasConflicts(opt) || isZero(opt.ArgP)) {
+			continue
+		}
+		// Only the values of secrets are redacted, not file names.
+		redact := opt.Secret && cfg.Redact
+		if s, ok := opt.ArgP.(*Secret); ok && s.file != "" {
+			redact = false
+		}
+		for _, val := range opt.argValues() {
+			if redact {
+				val = redacted
+			}
+			if arg := o.arg(opt, val); arg != "" {
+				args = append(args, arg)
+			}
+		}
+	}
+	return args
+}
+
+// hasConflicts returns true if the option conflicts with any other.
+func (o *Options) hasConflicts(opt *Option) bool {
+	for _, a := range o.allOpts {
+		if a != opt && conflicts(a, opt) {
+			return true
+		}
+	}
+	return false
+}
+
+// isZero returns true if p points to a zero value, or if p is a wrapper
+// that holds no value, or whose Target does.
+func isZero(p interface{}) bool {
+	switch v := p.(type) {
+	case nil:
+		return true
+	case *Secret:
+		return !v.IsSet()
+	case *File:
+		// Write and Append are settings, not values.
+		return v.Name == ""
+	case *HostPort:
+		// As is DefaultPort.
+		return v.Host == "" && v.Port == 0
+	case *Map:
+		p = v.Target
+	case *List:
+		p = v.Target
+	}
+	pv := reflect.ValueOf(p)
+	if pv.Kind() != reflect.Ptr {
+		return false
+	}
+	if pv = pv.Elem(); pv.Kind() == reflect.Struct {
+		if t := pv.FieldByName("Target"); t.IsValid() &&
+			t.Kind() == reflect.Ptr && !t.IsNil() {
+			// One of our wrappers.
+			pv = t.Elem()
+		}
+	}
+	switch pv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return pv.Len() == 0
+	}
+	return pv.IsZero()
+}
+
+// arg formats a single occurrence of the option.
+func (o *Options) arg(opt *Option, val string) string {
+	if long := opt.longName(); long != "" {
+		switch {
+		case !opt.HasArg && !opt.isFlag(), opt.isFlag() && val == "true":
+			return "--" + long
+		}
+		return "--" + long + "=" + val
+	}
+	name := "-" + string(opt.Short)
+	equals := o.shortOpts['='] == nil
+	switch {
+	case !opt.HasArg && !opt.isFlag(), opt.isFlag() && val == "true":
+		return name
+	case opt.isFlag() && !equals:
+		// There is no way to give a value.
+		return ""
+	case equals:
+		return name + "=" + val
+	}
+	return name + val
+}
+
+// argValues returns the values to give for the option to reproduce
+// its state, one for each occurrence.  For options without values, the
+// values are empty.
+func (opt *Option) argValues() []string {
+	switch p := opt.ArgP.(type) {
+	case nil:
+		if opt.HasArg {
+			if opt.Seen {
+				return []string{opt.Raw}
+			}
+			return nil
+		}
+		return make([]string, opt.count)
+	case *Secret:
+		if p.file != "" {
+			return []string{p.file}
+		}
+		return []string{p.Value()}
+	case *Map:
+		return mapPairs(p.Target, opt.Base)
+	case *List:
+		if isZero(p) {
+			return nil
+		}
+		return []string{p.format(opt.Base)}
+	case converter:
+		return []string{formatValue(p)}
+	case **regexp.Regexp:
+		if *p == nil {
+			return nil
+		}
+	}
+	if isStringMap(opt.ArgP) {
+		return mapPairs(opt.ArgP, opt.Base)
+	}
+	if _, ok := opt.ArgP.(encoding.TextUnmarshaler); !ok && isSlice(opt.ArgP) {
+		var vals []string
+		sv := reflect.ValueOf(opt.ArgP).Elem()
+		for i := 0; i < sv.Len(); i++ {
+			vals = append(vals, formatArg(sv.Index(i).Addr().Interface(), opt.Base))
+		}
+		return vals
+	}
+	return []string{formatArg(opt.ArgP, opt.Base)}
+}
+
+// mapPairs returns the key=value pairs of the map p points to,
+// sorted by key.
+func mapPairs(p interface{}, base int) []string {
+	mv := reflect.ValueOf(p).Elem()
+	var pairs []string
+	for _, k := range mv.MapKeys() {
+		v := reflect.New(mv.Type().Elem())
+		v.Elem().Set(mv.MapIndex(k))
+		pairs = append(pairs, k.String()+"="+formatArg(v.Interface(), base))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// formatArg returns the value p points to in a form that can be
+// parsed again, with integers in the given base.
+func formatArg(p interface{}, base int) string {
+	if base == 0 {
+		return formatValue(p)
+	}
+	if n, ok := p.(*big.Int); ok {
+		return n.Text(base)
+	}
+	switch v := reflect.ValueOf(p).Elem(); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), base)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), base)
+	}
+	return formatValue(p)
+}
@@ -0,0 +1,233 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// values returns the values of all the options, for comparison.
+func values(opts *Options) string {
+	var vals []string
+	for _, opt := range opts.All() {
+		vals = append(vals, opt.name()+"="+opt.valueString())
+		if s, ok := opt.ArgP.(*Secret); ok {
+			vals = append(vals, s.Value())
+		}
+	}
+	return strings.Join(vals, " ")
+}
+
+func TestOptions_Args(t *testing.T) {
+	var verbose, color bool
+	level := 3
+	var mask uint16
+	var ratio float64
+	var n big.Int
+	timeout := 5 * time.Second
+	var since time.Time
+	var name string
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "verbose", Short: 'v', ArgP: &verbose})
+	mustAdd(t, opts, &Option{Long: "color", ArgP: &color})
+	mustAdd(t, opts, &Option{Short: 'q'})
+	mustAdd(t, opts, &Option{Long: "debug", Repeat: RepeatAccumulate})
+	mustAdd(t, opts, &Option{Long: "level", ArgP: &level})
+	mustAdd(t, opts, &Option{Long: "mask", ArgP: &mask, Base: 16})
+	mustAdd(t, opts, &Option{Long: "ratio", ArgP: &ratio})
+	mustAdd(t, opts, &Option{Long: "n", ArgP: &n})
+	mustAdd(t, opts, &Option{Long: "timeout", ArgP: &timeout})
+	mustAdd(t, opts, &Option{Long: "since", ArgP: &Time{Target: &since}})
+	mustAdd(t, opts, &Option{Long: "name", ArgP: &name})
+	mustAdd(t, opts, &Option{Long: "raw", HasArg: true})
+
+	if args := opts.Args(ArgsConfig{}); strings.Join(args, " ") != "--level=3 --timeout=5s" {
+		t.Errorf("wrong defaults: %q", args)
+	}
+	if args := opts.Args(ArgsConfig{OmitDefaults: true}); len(args) != 0 {
+		t.Errorf("wrong args: %q", args)
+	}
+
+	_ = mustParse(t, opts, []string{
+		"-v", "--color=false", "-qq", "--debug", "--debug", "--level=-2",
+		"--mask", "ff", "--ratio", "0.25", "--n", "123456789012345678901234567890",
+		"--since", "2020-01-02T03:04:05Z", "--name", "-dash value", "--raw", "x=y",
+	})
+	want := "--verbose --color=false -q -q --debug --debug --level=-2 --mask=ff " +
+		"--ratio=0.25 --n=123456789012345678901234567890 --timeout=5s " +
+		"--since=2020-01-02T03:04:05Z --name=-dash value --raw=x=y"
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != want {
+		t.Errorf("wrong args:\n%s\n%s", strings.Join(args, " "), want)
+	}
+	if len(args) != 14 || args[12] != "--name=-dash value" {
+		t.Errorf("wrong split: %q", args)
+	}
+
+	// Parsing the result gives the same values.
+	before := values(opts)
+	verbose, color, level, mask, ratio, timeout, name = false, false, 3, 0, 0, 5*time.Second, ""
+	n.SetInt64(0)
+	since = time.Time{}
+	opts.Reset()
+	_ = mustParse(t, opts, args)
+	if values(opts) != before {
+		t.Errorf("values differ:\n%s\n%s", values(opts), before)
+	}
+}
+
+func TestOptions_ArgsRepeated(t *testing.T) {
+	var tags []string
+	var labels map[string]int
+	var list []string
+	var hex []int
+	var re *regexp.Regexp
+	var size Size
+	var glob Glob
+	var key []byte
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "tag", ArgP: &tags})
+	mustAdd(t, opts, &Option{Long: "label", ArgP: &labels})
+	mustAdd(t, opts, &Option{Long: "list", ArgP: &List{Target: &list}})
+	mustAdd(t, opts, &Option{Long: "hex", ArgP: &List{Target: &hex}, Base: 16})
+	mustAdd(t, opts, &Option{Long: "match", ArgP: &re})
+	mustAdd(t, opts, &Option{Long: "size", ArgP: &size})
+	mustAdd(t, opts, &Option{Long: "glob", ArgP: &glob})
+	mustAdd(t, opts, &Option{Long: "key", ArgP: &Bytes{Target: &key, Encoding: BytesBase64}})
+
+	_ = mustParse(t, opts, []string{
+		"--tag", "b", "--tag", "a", "--label", "z=1", "--label", "y=0x10",
+		"--list", `"a,b",c`, "--hex", "ff,10", "--match", "^x.*", "--size", "1.5KiB",
+		"--glob", "*.go", "--key", "+/8Afg==",
+	})
+	want := "--tag=b --tag=a --label=y=16 --label=z=1 --list=\"a,b\",c --hex=ff,10 " +
+		"--match=^x.* --size=1536B --glob=*.go --key=+/8Afg=="
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != want {
+		t.Errorf("wrong args:\n%s\n%s", strings.Join(args, " "), want)
+	}
+
+	// Items of lists are written in the base of the option, so parsing
+	// the result gives the same values.
+	before := values(opts)
+	tags, labels, list, hex, re, size, glob, key = nil, nil, nil, nil, nil, 0, "", nil
+	opts.Reset()
+	_ = mustParse(t, opts, args)
+	if values(opts) != before || len(hex) != 2 || hex[0] != 255 || hex[1] != 16 {
+		t.Errorf("values differ:\n%s\n%s", values(opts), before)
+	}
+}
+
+func TestOptions_ArgsSecret(t *testing.T) {
+	pwFile := filepath.Join(t.TempDir(), "pw")
+	if e := os.WriteFile(pwFile, []byte("hunter2\n"), 0600); e != nil {
+		t.Fatalf("cannot write file: %v", e)
+	}
+	pw := &Secret{}
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Short: 'p', ArgP: pw})
+	mustAdd(t, opts, &Option{Long: "password-file", ArgP: &Secret{FromFile: true}})
+	_ = mustParse(t, opts, []string{"-p", "swordfish", "--password-file", pwFile})
+
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != "-p=swordfish --password-file="+pwFile {
+		t.Errorf("wrong args: %q", args)
+	}
+	// Only the values of secrets are redacted, not the names of files.
+	args = opts.Args(ArgsConfig{Redact: true})
+	if strings.Join(args, " ") != "-p=<redacted> --password-file="+pwFile {
+		t.Errorf("not redacted: %q", args)
+	}
+}
+
+func TestOptions_ArgsRelations(t *testing.T) {
+	var name, keyFile, cert string
+	yaml := true
+	opts := &Options{}
+	oJSON := &Option{Long: "json", ArgP: new(bool)}
+	oCert := &Option{Long: "cert", ArgP: &cert}
+	mustAdd(t, opts, &Option{Long: "name", ArgP: &name})
+	mustAdd(t, opts, oJSON)
+	mustAdd(t, opts, &Option{Long: "yaml", ArgP: &yaml, Conflicts: []*Option{oJSON}})
+	mustAdd(t, opts, oCert)
+	mustAdd(t, opts, &Option{Long: "key-file", ArgP: &keyFile, Requires: []*Option{oCert}})
+	mustAdd(t, opts, &Option{Long: "all", Implies: []*Option{oJSON}})
+
+	// Neither --json, which was implied by --all, nor --yaml, which
+	// conflicts with it, is given.
+	_ = mustParse(t, opts, []string{"--key-file=k.pem", "--cert", "c.pem", "--all"})
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != "--cert=c.pem --key-file=k.pem --all" {
+		t.Errorf("wrong args: %q", args)
+	}
+
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--name", "x", "--all"})
+	args = opts.Args(ArgsConfig{OmitDefaults: true})
+	if strings.Join(args, " ") != "--name=x --all" {
+		t.Errorf("wrong args: %q", args)
+	}
+}
+
+func TestOptions_ArgsUnset(t *testing.T) {
+	// Wrappers with settings, but no value, are not given.
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "out", ArgP: &File{Write: true, Append: true}})
+	mustAdd(t, opts, &Option{Long: "listen", ArgP: &HostPort{DefaultPort: 8080}})
+	if args := opts.Args(ArgsConfig{}); len(args) != 0 {
+		t.Errorf("wrong args: %q", args)
+	}
+	_ = mustParse(t, opts, []string{"--out", "o.log", "--listen", "localhost"})
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != "--out=o.log --listen=localhost:8080" {
+		t.Errorf("wrong args: %q", args)
+	}
+}
+
+func TestOptions_ArgsShort(t *testing.T) {
+	var a, b bool
+	a = true
+	var s string
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Short: 'a', ArgP: &a})
+	mustAdd(t, opts, &Option{Short: 's', ArgP: &s})
+	_ = mustParse(t, opts, []string{"-a=n", "-s", "=x"})
+	args := opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != "-a=false -s==x" {
+		t.Errorf("wrong args: %q", args)
+	}
+
+	// With '=' as an option, values cannot be given with it, so the
+	// false flag cannot be given at all.
+	mustAdd(t, opts, &Option{Short: '=', ArgP: &b})
+	args = opts.Args(ArgsConfig{})
+	if strings.Join(args, " ") != "-s=x" {
+		t.Errorf("wrong args: %q", args)
+	}
+	s = ""
+	opts.Reset()
+	_ = mustParse(t, opts, args)
+	if s != "=x" || b {
+		t.Errorf("did not round trip: %q", s)
+	}
+}
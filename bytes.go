@@ -0,0 +1,117 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+// BytesEncoding is the encoding of binary values given to a Bytes option.
+type BytesEncoding int
+
+const (
+	// BytesHex is hexadecimal, in upper or lower case.
+	BytesHex BytesEncoding = iota
+
+	// BytesBase64 is standard base64, with padding.
+	BytesBase64
+
+	// BytesRawBase64 is standard base64, without padding.
+	BytesRawBase64
+
+	// BytesURLBase64 is the URL and file name safe base64, with padding.
+	BytesURLBase64
+
+	// BytesRawURLBase64 is the URL and file name safe base64,
+	// without padding.
+	BytesRawURLBase64
+)
+
+func (enc BytesEncoding) base64() *base64.Encoding {
+	switch enc {
+	case BytesBase64:
+		return base64.StdEncoding
+	case BytesRawBase64:
+		return base64.RawStdEncoding
+	case BytesURLBase64:
+		return base64.URLEncoding
+	case BytesRawURLBase64:
+		return base64.RawURLEncoding
+	}
+	return nil
+}
+
+// Bytes is used as an ArgP for options that take binary values, such as
+// keys, given as text in some encoding.  Errors do not include the value,
+// although they do give the position of the problem in it.
+type Bytes struct {
+	// Target is where the decoded value is stored.
+	Target *[]byte
+
+	// Encoding is the encoding used, by default BytesHex.
+	Encoding BytesEncoding
+}
+
+func (b *Bytes) decode(val string) ([]byte, error) {
+	if enc := b.Encoding.base64(); enc != nil {
+		v, e := enc.DecodeString(val)
+		var ce base64.CorruptInputError
+		if errors.As(e, &ce) {
+			return nil, &posError{msg: "invalid base64", val: val, pos: int(ce)}
+		}
+		return v, e
+	}
+	for i := 0; i < len(val); i++ {
+		switch c := val[i]; {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return nil, &posError{msg: "invalid hex", val: val, pos: i}
+		}
+	}
+	if len(val)%2 != 0 {
+		return nil, errors.New("hex value has an odd number of digits")
+	}
+	return hex.DecodeString(val)
+}
+
+func (b *Bytes) convert(val string, _ *Option) (interface{}, func(), error) {
+	v, e := b.decode(val)
+	if e != nil {
+		return nil, nil, e
+	}
+	return v, func() { *b.Target = v }, nil
+}
+
+func (b *Bytes) hint() (string, string) {
+	if b.Encoding.base64() != nil {
+		return "BASE64", ""
+	}
+	return "HEX", ""
+}
+
+// String returns the value, using the same encoding.
+func (b *Bytes) String() string {
+	if b.Target == nil {
+		return ""
+	}
+	if enc := b.Encoding.base64(); enc != nil {
+		return enc.EncodeToString(*b.Target)
+	}
+	return hex.EncodeToString(*b.Target)
+}
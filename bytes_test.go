@@ -0,0 +1,84 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	data := []byte{0xfb, 0xff, 0x00, 0x7e}
+	for _, c := range []struct {
+		enc  BytesEncoding
+		good []string
+		bad  []string
+		msg  string
+	}{
+		{BytesHex, []string{"fbff007e", "FBFF007E"}, []string{"fbff007", "fbf-007e"}, "invalid hex at position 4"},
+		{BytesBase64, []string{"+/8Afg=="}, []string{"+/8Afg", "-_8Afg=="}, "invalid base64 at position 1"},
+		{BytesRawBase64, []string{"+/8Afg"}, []string{"+/8Afg==", "-_8Afg"}, "invalid base64 at position 1"},
+		{BytesURLBase64, []string{"-_8Afg=="}, []string{"-_8Afg", "+/8Afg=="}, "invalid base64 at position 1"},
+		{BytesRawURLBase64, []string{"-_8Afg"}, []string{"-_8Afg==", "+/8Afg"}, "invalid base64 at position 1"},
+	} {
+		var val []byte
+		b := &Bytes{Target: &val, Encoding: c.enc}
+		opts := &Options{}
+		mustAdd(t, opts, &Option{Long: "key", ArgP: b})
+		for _, good := range c.good {
+			opts.Reset()
+			_ = mustParse(t, opts, []string{"--key", good})
+			if !bytes.Equal(val, data) {
+				t.Errorf("%d: %s: wrong value %x", c.enc, good, val)
+			}
+			if b.String() != c.good[0] {
+				t.Errorf("%d: wrong string %q", c.enc, b.String())
+			}
+		}
+		for _, bad := range c.bad {
+			opts.Reset()
+			mustNotParse(t, opts, []string{"--key", bad})
+		}
+		opts.Reset()
+		_, e := opts.Parse([]string{"--key", c.bad[1]})
+		if e == nil || e.Error() != "failure parsing option value: --key: "+c.msg {
+			t.Errorf("%d: wrong error: %v", c.enc, e)
+		}
+		if !bytes.Equal(val, data) {
+			t.Errorf("%d: value changed by failed parse: %x", c.enc, val)
+		}
+	}
+}
+
+func TestBytes_Help(t *testing.T) {
+	opts := &Options{}
+	var a, b []byte
+	e := opts.Add(
+		&Option{Long: "key-hex", ArgP: &Bytes{Target: &a}, Help: "Key"},
+		&Option{Long: "token-b64", ArgP: &Bytes{Target: &b, Encoding: BytesRawURLBase64}, Help: "Token"},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --key-hex HEX         Key
+  --token-b64 BASE64    Token
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
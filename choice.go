@@ -0,0 +1,121 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// choose checks val against the choices for the option, returning
+// the canonical spelling of the choice.
+func (opt *Option) choose(val string) (string, error) {
+	for _, c := range opt.Choices {
+		if c == val || (opt.FoldChoices && strings.EqualFold(c, val)) {
+			return c, nil
+		}
+	}
+	if opt.Secret {
+		// Neither the value, nor how close it is to a choice.
+		return "", fmt.Errorf("invalid choice (valid choices: %s)",
+			strings.Join(opt.Choices, ", "))
+	}
+	msg := fmt.Sprintf("invalid choice %q (valid choices: %s)",
+		val, strings.Join(opt.Choices, ", "))
+	if s := opt.suggestChoice(val); s != "" {
+		msg += fmt.Sprintf("; did you mean %q?", s)
+	}
+	return "", errors.New(msg)
+}
+
+// suggestChoice returns the choice closest to val, provided it is
+// close enough to plausibly be a typo.
+func (opt *Option) suggestChoice(val string) string {
+	best := ""
+	bestDist := 3 // anything further away is not a useful suggestion
+	if opt.FoldChoices {
+		val = strings.ToLower(val)
+	}
+	for _, c := range opt.Choices {
+		cmp := c
+		if opt.FoldChoices {
+			cmp = strings.ToLower(c)
+		}
+		if d := editDistance(val, cmp); d < bestDist {
+			best = c
+			bestDist = d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// choiceHelp returns the text to append to the option's help,
+// and any additional lines describing the individual choices.
+func (opt *Option) choiceHelp() (string, []string) {
+	if len(opt.Choices) == 0 {
+		return "", nil
+	}
+	if len(opt.ChoiceHelp) == 0 {
+		return " (one of: " + strings.Join(opt.Choices, ", ") + ")", nil
+	}
+	width := 0
+	for _, c := range opt.Choices {
+		if len(c) > width {
+			width = len(c)
+		}
+	}
+	var lines []string
+	for _, c := range opt.Choices {
+		line := fmt.Sprintf("  %-*s  %s", width, c, opt.ChoiceHelp[c])
+		lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	return "", lines
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestChoices(t *testing.T) {
+	opts := &Options{}
+	var val string
+	var handled string
+	o := &Option{
+		Long:    "format",
+		ArgP:    &val,
+		Choices: []string{"json", "text", "yaml"},
+		Handle: func(s string) error {
+			handled = s
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"--format", "text"})
+	if val != "text" || handled != "text" {
+		t.Errorf("wrong value %q", val)
+	}
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"--format=jsn"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: --format=jsn: `+
+		`invalid choice "jsn" (valid choices: json, text, yaml); `+
+		`did you mean "json"?` {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	opts.Reset()
+	_, e = opts.Parse([]string{"--format=xml-document"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: --format=xml-document: `+
+		`invalid choice "xml-document" (valid choices: json, text, yaml)` {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	// Case matters by default.
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--format", "JSON"})
+}
+
+func TestChoices_Fold(t *testing.T) {
+	opts := &Options{}
+	var val string
+	o := &Option{
+		Short:       'f',
+		ArgP:        &val,
+		Choices:     []string{"json", "Text"},
+		FoldChoices: true,
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"-fJSON"})
+	if val != "json" || o.Raw != "JSON" {
+		t.Errorf("wrong value %q", val)
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-f", "text"})
+	if val != "Text" {
+		t.Errorf("wrong value %q", val)
+	}
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"-f", "TXET"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: -f: `+
+		`invalid choice "TXET" (valid choices: json, Text); `+
+		`did you mean "Text"?` {
+		t.Errorf("wrong message: %v", e)
+	}
+}
+
+func TestChoices_Help(t *testing.T) {
+	opts := &Options{}
+	e := opts.Add(&Option{
+		Long:    "format",
+		ArgName: "FMT",
+		HasArg:  true,
+		Help:    "Output format",
+		Choices: []string{"json", "text"},
+	}, &Option{
+		Long:    "color",
+		ArgName: "WHEN",
+		HasArg:  true,
+		Help:    "Colorize output",
+		Choices: []string{"auto", "always", "never"},
+		ChoiceHelp: map[string]string{
+			"auto":   "Only on terminals",
+			"always": "Always",
+		},
+	})
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --format FMT    Output format (one of: json, text)
+  --color WHEN    Colorize output
+                    auto    Only on terminals
+                    always  Always
+                    never
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
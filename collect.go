@@ -0,0 +1,78 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"strings"
+)
+
+// ParseErrors is the error returned by Options.Parse when
+// Options.CollectErrors is set, and there were errors in the arguments.
+//
+// Parsing carries on past unknown options (skipping them), bad values,
+// repeated options, and errors returned by handlers (which are reported
+// with code ErrInvalidOptions).  Missing and conflicting options are
+// then all reported.  An option at the end of the arguments that needs
+// a value ends parsing.  The errors are in the order they were found,
+// and each records its Option and Source, for use with
+// Options.Diagnostic.  Errors returned by the BeforeParse, AfterParse
+// and Validate hooks are returned as they are, without collecting, and
+// AfterParse and Validate are not called if errors were collected.
+//
+// Use errors.Is or errors.As to look for a particular error.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, pe := range e {
+		msgs = append(msgs, pe.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, pe := range e {
+		errs = append(errs, pe)
+	}
+	return errs
+}
+
+// handlerError wraps an error returned by a handler for the option,
+// when errors are being collected, so that it records the option.
+func (o *Options) handlerError(e error, opt *Option, arg string, src Source) error {
+	if _, ok := e.(*ParseError); ok || e == nil || !o.CollectErrors {
+		return e
+	}
+	return &ParseError{Code: ErrInvalidOptions, Arg: arg, Option: opt, Err: e, Source: src}
+}
+
+// fail handles an error found while parsing.  If errors are being
+// collected, it records the error and returns nil, so that parsing
+// carries on.  Otherwise it returns the error.
+func (p *parser) fail(e error) error {
+	if e == nil || !p.o.CollectErrors {
+		return e
+	}
+	pe, ok := e.(*ParseError)
+	if !ok {
+		pe = &ParseError{Code: ErrInvalidOptions, Err: e}
+	}
+	p.errs = append(p.errs, pe)
+	return nil
+}
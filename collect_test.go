@@ -0,0 +1,142 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOptions_CollectErrors(t *testing.T) {
+	var port, count int
+	var verbose bool
+	validated := false
+	opts := &Options{
+		CollectErrors: true,
+		Validate:      func() error { validated = true; return nil },
+	}
+	oCert := &Option{Long: "cert", HasArg: true}
+	e := opts.Add(
+		&Option{Long: "port", Short: 'p', ArgP: &port},
+		&Option{Long: "count", Short: 'c', ArgP: &count, MaxCount: 1},
+		&Option{Long: "verbose", Short: 'v', ArgP: &verbose},
+		&Option{Long: "name", MinCount: 1, HasArg: true},
+		&Option{Long: "even", HasArg: true, Handle: func(v string) error {
+			if len(v)%2 != 0 {
+				return errors.New("odd length")
+			}
+			return nil
+		}},
+		oCert,
+		&Option{Long: "key", HasArg: true, Requires: []*Option{oCert}},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	args := []string{
+		"--colour", "-vxp", "8x0", "-c1", "-c", "2", "--even=abc",
+		"--key", "k", "-v", "--port",
+	}
+	_, e = opts.Parse(args)
+	var pes ParseErrors
+	if !errors.As(e, &pes) {
+		t.Fatalf("wrong error: %v", e)
+	}
+	want := []string{
+		"no such option: --colour",
+		"no such option: -xp",
+		"failure parsing option value: -p: invalid syntax",
+		"option repeated: -c: at argv[4], may be given at most 1 times",
+		"invalid options: --even=abc: odd length",
+		"option requires value: --port",
+		"missing required option: --cert: required by --key",
+		"missing required option: --name",
+	}
+	if e.Error() != strings.Join(want, "\n") {
+		t.Errorf("wrong errors:\n%v", e)
+	}
+	index := []int{0, 1, 2, 4, 6, 10, 7, -1}
+	for i, pe := range pes {
+		if i < len(index) && index[i] >= 0 &&
+			(pe.Source.Kind != SourceArgs || pe.Source.Index != index[i]) {
+			t.Errorf("wrong source for %v: %v", pe, pe.Source)
+		}
+	}
+	if pes[2].Option != opts.Lookup("port") || pes[7].Option != opts.Lookup("name") {
+		t.Errorf("wrong options")
+	}
+	if !verbose || count != 1 || validated {
+		t.Errorf("wrong state: %v %d %v", verbose, count, validated)
+	}
+	for _, code := range []error{ErrNoSuchOption, ErrParsingValue, ErrRepeatedOption,
+		ErrInvalidOptions, ErrOptionRequiresValue, ErrMissingOption} {
+		if !errors.Is(e, code) {
+			t.Errorf("not %v", code)
+		}
+	}
+	if errors.Is(e, ErrConflictingOptions) {
+		t.Errorf("wrong code")
+	}
+
+	got := opts.Diagnostic(e, args, DiagnosticConfig{})
+	if strings.Count(got, "error:") != len(want) ||
+		!strings.Contains(got, "error: no such option: -xp\n  --colour -vxp ...\n             ^\n") {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+
+	// Without errors, the hooks run as usual.
+	opts.Reset()
+	rest := mustParse(t, opts, []string{"--name", "x", "file"})
+	if len(rest) != 1 || !validated {
+		t.Errorf("wrong result")
+	}
+
+	// Hook errors are not collected.
+	opts.Validate = func() error { return errors.New("bad") }
+	opts.Reset()
+	_, e = opts.Parse([]string{"--name", "x"})
+	if _, ok := e.(*ParseError); !ok || !errors.Is(e, ErrInvalidOptions) {
+		t.Errorf("wrong error: %v", e)
+	}
+}
+
+func TestOptions_CollectImplied(t *testing.T) {
+	opts := &Options{CollectErrors: true}
+	var calls int
+	oQuiet := &Option{Long: "quiet", Handle: func(string) error {
+		calls++
+		return errors.New("no quiet")
+	}}
+	oVerbose := &Option{Long: "verbose", Conflicts: []*Option{oQuiet}}
+	oAll := &Option{Long: "all", Implies: []*Option{oQuiet}}
+	mustAdd(t, opts, oQuiet)
+	mustAdd(t, opts, oVerbose)
+	mustAdd(t, opts, oAll)
+	_, e := opts.Parse([]string{"--verbose", "--all"})
+	want := "invalid options: --quiet: no quiet\n" +
+		"conflicting options: --verbose: conflicts with --quiet"
+	if e == nil || e.Error() != want || calls != 1 {
+		t.Errorf("wrong error: %v", e)
+	}
+	var pes ParseErrors
+	if !errors.As(e, &pes) || pes[0].Option != oQuiet ||
+		pes[0].Source.String() != "implied by --all" {
+		t.Errorf("wrong error details")
+	}
+}
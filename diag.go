@@ -0,0 +1,260 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// place is a part of one of the arguments passed to Parse.  If start
+// and end are both zero, it is the whole argument.
+type place struct {
+	index      int // index of the argument
+	start, end int // byte offsets within the argument
+}
+
+// within narrows the place of a value to the position of the problem
+// reported by e, if it gives one for that value.
+func (at place) within(arg, val string, e error) place {
+	var pe *posError
+	if !errors.As(e, &pe) || pe.val != val {
+		return at
+	}
+	if at.start == 0 && at.end == 0 {
+		at.end = len(arg)
+	}
+	pos := pe.pos
+	if pos > len(val) {
+		pos = len(val)
+	}
+	at.start += pos
+	at.end = at.start
+	if pos < len(val) {
+		_, n := utf8.DecodeRuneInString(val[pos:])
+		at.end += n
+	}
+	return at
+}
+
+// redact returns the place within the argument after the part at s
+// is replaced by the redacted placeholder.  If the place overlaps that
+// part, it covers all of the placeholder.
+func (at place) redact(s place) place {
+	delta := len(redacted) - (s.end - s.start)
+	switch {
+	case at.end <= s.start && at.end > at.start:
+		// Before the secret.
+	case at.start >= s.end:
+		at.start += delta
+		at.end += delta
+	default:
+		if at.start > s.start {
+			at.start = s.start
+		}
+		if at.end >= s.end {
+			at.end += delta
+		} else {
+			at.end = s.start + len(redacted)
+		}
+	}
+	return at
+}
+
+// DiagnosticConfig controls the output of Options.Diagnostic.
+type DiagnosticConfig struct {
+	// Program is the name of the program, shown before the arguments.
+	Program string
+
+	// Hint is shown after the error, for example "see --help".  If it
+	// is empty, and there is a --help option, that is suggested.
+	Hint string
+
+	// Color highlights the output with ANSI escape sequences,
+	// for display on terminals.
+	Color bool
+}
+
+// These are the ANSI escape sequences used by Diagnostic.
+const (
+	ansiError = "\x1b[1;31m"
+	ansiBold  = "\x1b[1m"
+	ansiCaret = "\x1b[1;32m"
+	ansiHint  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// Diagnostic describes an error returned by Parse, for display to users.
+// The args are those that were passed to Parse.  After the error, the
+// arguments are shown, as far as the one at fault, with that argument
+// (or its value, or the part of the value with the problem) underlined.
+// For example:
+//
+//	error: failure parsing option value: --port: invalid syntax
+//	  prog --verbose --port 8x0 file ...
+//	                        ^~~
+//	see --help
+//
+// The values of Secret options are redacted.  Arguments are quoted if
+// needed, as for a POSIX shell.  Errors that are not about a particular
+// argument are shown without the arguments, as are errors returned by
+// handlers, unless they carry a Source (see Option.Handle).  For
+// ParseErrors, each of the errors is shown in turn, followed by the hint.
+func (o *Options) Diagnostic(e error, args []string, cfg DiagnosticConfig) string {
+	color := func(code, s string) string {
+		if !cfg.Color || s == "" {
+			return s
+		}
+		return code + s + ansiReset
+	}
+	errs := []error{e}
+	if pes, ok := e.(ParseErrors); ok {
+		errs = pes.Unwrap()
+	}
+	result := &strings.Builder{}
+	for _, e := range errs {
+		_, _ = result.WriteString(color(ansiError, "error:") + " " +
+			color(ansiBold, e.Error()) + "\n")
+
+		var pe *ParseError
+		if errors.As(e, &pe) && pe.Source.Kind == SourceArgs &&
+			pe.Source.Index < len(args) {
+			line, caret := o.showArgs(cfg.Program, args, pe, color)
+			_, _ = result.WriteString("  " + line + "\n")
+			_, _ = result.WriteString("  " + color(ansiCaret, caret) + "\n")
+		}
+	}
+
+	hint := cfg.Hint
+	if hint == "" && o.Lookup("help") != nil {
+		hint = "see --help"
+		if cfg.Program != "" {
+			hint = "see '" + cfg.Program + " --help'"
+		}
+	}
+	if hint != "" {
+		_, _ = result.WriteString(color(ansiHint, hint) + "\n")
+	}
+	return result.String()
+}
+
+// showArgs returns the arguments as far as the one at fault, with
+// the line to put under them.
+func (o *Options) showArgs(program string, args []string, pe *ParseError,
+	color func(string, string) string) (string, string) {
+	at := place{index: pe.Source.Index, start: pe.start, end: pe.end}
+	if at.start == 0 && at.end == 0 {
+		at.end = len(args[at.index])
+	}
+	secrets := o.secretPlaces()
+	known := false
+	for _, s := range secrets {
+		known = known || s.index == at.index
+	}
+	if s, ok := o.clusterSecret(args[at.index], at.index); ok && !known {
+		secrets = append(secrets, s)
+	}
+
+	var line, under []string
+	if program != "" {
+		line = append(line, program)
+		under = append(under, strings.Repeat(" ", utf8.RuneCountInString(program)))
+	}
+	for i, arg := range args[:at.index+1] {
+		for _, s := range secrets {
+			if s.index == i {
+				if s.start == 0 && s.end == 0 {
+					s.end = len(arg)
+				}
+				if at.index == i {
+					at = at.redact(s)
+				}
+				arg = arg[:s.start] + redacted + arg[s.end:]
+			}
+		}
+		text, col := quoteArg(arg)
+		if i != at.index {
+			line = append(line, text)
+			under = append(under, strings.Repeat(" ", utf8.RuneCountInString(text)))
+			continue
+		}
+		start, end := col(at.start), col(at.end)
+		line = append(line, text[:start]+color(ansiError, text[start:end])+text[end:])
+		n := utf8.RuneCountInString(text[start:end])
+		caret := "^"
+		if n > 1 {
+			caret += strings.Repeat("~", n-1)
+		}
+		under = append(under, strings.Repeat(" ", utf8.RuneCountInString(text[:start]))+caret)
+	}
+	if at.index+1 < len(args) {
+		line = append(line, "...")
+	}
+	return strings.Join(line, " "), strings.Join(under, " ")
+}
+
+// secretPlaces returns the places of the values of Secret options
+// given in the arguments.
+func (o *Options) secretPlaces() []place {
+	var places []place
+	for _, occ := range o.occurrences {
+		if occ.Option.Secret && occ.Source.Kind == SourceArgs {
+			places = append(places, occ.value)
+		}
+	}
+	return places
+}
+
+// clusterSecret returns the place of a value for a Secret option in a
+// cluster of short options, which might not have been stored if
+// parsing failed before it was reached.
+func (o *Options) clusterSecret(arg string, index int) (place, bool) {
+	if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+		return place{}, false
+	}
+	r, _ := o.redactCluster(arg)
+	if r == arg {
+		return place{}, false
+	}
+	return place{index: index, start: len(r) - len(redacted), end: len(arg)}, true
+}
+
+// quoteArg quotes the argument, if needed, for a POSIX shell.  It also
+// returns a function to map a byte offset in the argument to the
+// corresponding byte offset in the quoted form.
+func quoteArg(arg string) (string, func(int) int) {
+	safe := func(r rune) bool {
+		return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) ||
+			strings.ContainsRune("-_=+,.:/@%^", r))
+	}
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool { return !safe(r) }) < 0 {
+		return arg, func(i int) int { return i }
+	}
+	if !strings.ContainsRune(arg, '\'') && strings.IndexFunc(arg, func(r rune) bool {
+		return !unicode.IsPrint(r) && r != ' '
+	}) < 0 {
+		return "'" + arg + "'", func(i int) int { return i + 1 }
+	}
+	// Fall back to Go quoting, which shells will not understand,
+	// but which shows everything.
+	return strconv.Quote(arg), func(i int) int {
+		return len(strconv.Quote(arg[:i])) - 1
+	}
+}
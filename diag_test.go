@@ -0,0 +1,242 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Diagnostic(t *testing.T) {
+	var port int
+	var re *regexp.Regexp
+	var tags []string
+	opts := &Options{}
+	oCert := &Option{Long: "cert", HasArg: true}
+	mustAdd(t, opts, &Option{Long: "verbose", Short: 'v', ArgP: new(bool)})
+	mustAdd(t, opts, &Option{Long: "port", Short: 'p', ArgP: &port})
+	mustAdd(t, opts, &Option{Long: "name", Short: 'n', HasArg: true, MaxCount: 1})
+	mustAdd(t, opts, &Option{Long: "match", ArgP: &re})
+	mustAdd(t, opts, &Option{Long: "tags", ArgP: &List{Target: &tags}})
+	mustAdd(t, opts, &Option{Long: "password", Short: 'P', ArgP: &Secret{}})
+	mustAdd(t, opts, oCert)
+	mustAdd(t, opts, &Option{Long: "key", HasArg: true, Requires: []*Option{oCert}})
+	mustAdd(t, opts, &Option{Long: "help", Short: 'h'})
+
+	for _, c := range []struct {
+		args []string
+		code error
+		want string
+	}{
+		{
+			[]string{"-v", "--port", "8x0", "file"}, ErrParsingValue,
+			`error: failure parsing option value: --port: invalid syntax
+  prog -v --port 8x0 ...
+                 ^~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"--port=8x0"}, ErrParsingValue,
+			`error: failure parsing option value: --port=8x0: invalid syntax
+  prog --port=8x0
+              ^~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"-vp8x0", "x"}, ErrParsingValue,
+			`error: failure parsing option value: -p8x0: invalid syntax
+  prog -vp8x0 ...
+          ^~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"-vxh"}, ErrNoSuchOption,
+			`error: no such option: -xh
+  prog -vxh
+         ^
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"--verbose", "--colour", "--name", "x"}, ErrNoSuchOption,
+			`error: no such option: --colour
+  prog --verbose --colour ...
+                 ^~~~~~~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"-v", "--port"}, ErrOptionRequiresValue,
+			`error: option requires value: --port
+  prog -v --port
+          ^~~~~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"--match", "a(b", "x"}, ErrParsingValue,
+			`error: failure parsing option value: --match: invalid regexp "a(b": missing closing ) at position 4
+  prog --match 'a(b' ...
+                   ^
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"--tags=a,\"b c\"x"}, ErrParsingValue,
+			`error: failure parsing option value: --tags=a,"b c"x: ` +
+				`missing separator after quote in "a,\"b c\"x" at position 8
+  prog '--tags=a,"b c"x'
+                      ^
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"-n", "a", "-P", "hunter2", "--name=it's", "-n", "b"}, ErrRepeatedOption,
+			`error: option repeated: --name=it's: at argv[4], may be given at most 1 times
+  prog -n a -P '<redacted>' "--name=it's" ...
+                             ^~~~~~
+see 'prog --help'
+`,
+		},
+		{
+			[]string{"--password=hunter2", "--key", "k"}, ErrMissingOption,
+			`error: missing required option: --cert: required by --key
+  prog '--password=<redacted>' --key ...
+                               ^~~~~
+see 'prog --help'
+`,
+		},
+	} {
+		opts.Reset()
+		_, e := opts.Parse(c.args)
+		if !errors.Is(e, c.code) {
+			t.Errorf("%q: wrong error: %v", c.args, e)
+			continue
+		}
+		got := opts.Diagnostic(e, c.args, DiagnosticConfig{Program: "prog"})
+		if got != c.want {
+			t.Errorf("%q: wrong diagnostic:\n%s\nwant:\n%s", c.args, got, c.want)
+		}
+		if strings.Contains(got, "hunter2") {
+			t.Errorf("secret shown")
+		}
+	}
+}
+
+func TestOptions_DiagnosticConfig(t *testing.T) {
+	var port int
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "password", ArgP: &Secret{}})
+	mustAdd(t, opts, &Option{Long: "port", ArgP: &port})
+	mustAdd(t, opts, &Option{Long: "help"})
+	args := []string{"--password", "pw", "--port", "x"}
+	_, e := opts.Parse(args)
+	mustFailAs(t, e, ErrParsingValue)
+
+	// Without a program name, or a --help option.
+	want := `error: failure parsing option value: --port: invalid syntax
+  --password '<redacted>' --port x
+                                 ^
+`
+	other := &Options{}
+	other.occurrences = opts.occurrences
+	if got := other.Diagnostic(e, args, DiagnosticConfig{}); got != want {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+
+	want = "\x1b[1;31merror:\x1b[0m \x1b[1mfailure parsing option value: --port: invalid syntax\x1b[0m\n" +
+		"  prog --password '<redacted>' --port \x1b[1;31mx\x1b[0m\n" +
+		"  \x1b[1;32m" + strings.Repeat(" ", 36) + "^\x1b[0m\n" +
+		"\x1b[36mtry harder\x1b[0m\n"
+	got := opts.Diagnostic(e, args, DiagnosticConfig{Program: "prog", Hint: "try harder", Color: true})
+	if got != want {
+		t.Errorf("wrong diagnostic:\n%q\n%q", got, want)
+	}
+
+	// Errors without a place in the arguments.
+	want = "error: boom\nsee --help\n"
+	if got := opts.Diagnostic(errors.New("boom"), args, DiagnosticConfig{}); got != want {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+	e = opts.Set("port", "y", Source{Kind: SourceEnv, Name: "PORT"})
+	want = "error: failure parsing option value: --port (env PORT): invalid syntax\nsee --help\n"
+	if got := opts.Diagnostic(e, args, DiagnosticConfig{}); got != want {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+
+	// Handler errors are shown in place only if they say where.
+	opts = &Options{}
+	mustAdd(t, opts, &Option{Long: "odd", HasArg: true,
+		Handle: func(string) error { return errors.New("odd") }})
+	mustAdd(t, opts, &Option{Long: "even", HasArg: true,
+		HandleContext: func(hc *HandlerContext) error {
+			return &ParseError{Code: ErrInvalidOptions, Arg: hc.Name, Option: hc.Option,
+				Err: errors.New("even"), Source: Source{Kind: SourceArgs, Index: hc.Index}}
+		}})
+	args = []string{"--odd", "x"}
+	_, e = opts.Parse(args)
+	if got := opts.Diagnostic(e, args, DiagnosticConfig{}); got != "error: odd\n" {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+	args = []string{"--odd=", "--even", "x"}
+	opts.Lookup("odd").Handle = nil
+	opts.Reset()
+	_, e = opts.Parse(args)
+	want = "error: invalid options: --even: even\n  --odd= --even ...\n         ^~~~~~\n"
+	if got := opts.Diagnostic(e, args, DiagnosticConfig{}); got != want {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+}
+
+func TestOptions_DiagnosticSecret(t *testing.T) {
+	for _, c := range []struct {
+		arg  string
+		want string
+	}{
+		{"--password=averyverylongsecretvalue12345678", `error: missing required option: --user: required by --password
+  '--password=<redacted>' ...
+   ^~~~~~~~~~~~~~~~~~~~~
+`},
+		{"--password=ab", `error: missing required option: --user: required by --password
+  '--password=<redacted>' ...
+   ^~~~~~~~~~~~~~~~~~~~~
+`},
+	} {
+		opts := &Options{}
+		oUser := &Option{Long: "user", HasArg: true}
+		mustAdd(t, opts, oUser)
+		mustAdd(t, opts, &Option{Long: "password", ArgP: &Secret{}, Requires: []*Option{oUser}})
+		mustAdd(t, opts, &Option{Short: 'v'})
+		args := []string{c.arg, "-v"}
+		_, e := opts.Parse(args)
+		mustFailAs(t, e, ErrMissingOption)
+		if got := opts.Diagnostic(e, args, DiagnosticConfig{}); got != c.want {
+			t.Errorf("wrong diagnostic:\n%s", got)
+		}
+	}
+
+	// A place after the secret moves with it.
+	at := place{start: 12, end: 13}.redact(place{start: 2, end: 4})
+	if at.start != 20 || at.end != 21 {
+		t.Errorf("wrong place: %v", at)
+	}
+}
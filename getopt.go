@@ -0,0 +1,88 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+	"iter"
+)
+
+// Getopt iterates over the options in a list of arguments, in the
+// manner of the classic getopt loop, as an alternative to Parse.
+// The options are recognized just as Parse would, but nothing is done
+// with them: values are not converted or stored, handlers and hooks
+// are not called, and the options are not checked for repeats, counts
+// or relations.  This is left to the caller.  For example:
+//
+//	g := opts.Getopt(os.Args[1:])
+//	for opt, val := range g.All() {
+//		switch opt {
+//		case verbose:
+//			level++
+//		case expr:
+//			exprs = append(exprs, val)
+//		case nil:
+//			files = append(files, val)
+//		}
+//	}
+//	if e := g.Err(); e != nil {
+//		...
+//	}
+type Getopt struct {
+	o    *Options
+	args []string
+	err  error
+}
+
+// Getopt returns an iterator over the options in args.
+func (o *Options) Getopt(args []string) *Getopt {
+	o.init()
+	return &Getopt{o: o, args: args}
+}
+
+// All returns an iterator over the options, in the order they are
+// given, with their values.  The values are as given, so flags have
+// the value "true" unless one is attached (as in -v=n), and options
+// without values have an empty value.  After the options, the residual
+// arguments are returned, each with a nil option.
+//
+// If an error is found (an unknown option, or one missing its value),
+// iteration stops, and Err returns the error.  If Options.CollectErrors
+// is set, iteration carries on past unknown options instead, and the
+// residual arguments are not returned if there were errors.
+func (g *Getopt) All() iter.Seq2[*Option, string] {
+	return func(yield func(*Option, string) bool) {
+		p := &parser{o: g.o, ctx: context.Background(), args: g.args, yield: yield}
+		args, e := p.run()
+		if g.err = e; e == nil && len(p.errs) > 0 {
+			g.err = p.errs
+		}
+		if g.err != nil || p.quit {
+			return
+		}
+		for _, arg := range args {
+			if !yield(nil, arg) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error found by the last iteration, if any.
+func (g *Getopt) Err() error {
+	return g.err
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Getopt(t *testing.T) {
+	var verbose bool
+	var port int
+	handled := false
+	opts := &Options{}
+	oVerbose := &Option{Long: "verbose", Short: 'v', ArgP: &verbose}
+	oPort := &Option{Long: "port", Short: 'p', ArgP: &port,
+		Handle: func(string) error { handled = true; return nil }}
+	oExpr := &Option{Short: 'e', HasArg: true}
+	oQuiet := &Option{Long: "quiet", Short: 'q', MaxCount: 1}
+	oAll := &Option{Long: "all", Implies: []*Option{oQuiet}}
+	if e := opts.Add(oVerbose, oPort, oExpr, oQuiet, oAll); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	args := []string{"-vqe", "a", "--port=x", "-q", "-eb", "--verbose=false", "--all",
+		"--", "-v", "file"}
+	g := opts.Getopt(args)
+	var got []string
+	for opt, val := range g.All() {
+		name := "residual"
+		if opt != nil {
+			name = opt.name()
+		}
+		got = append(got, name+"="+val)
+	}
+	want := "--verbose=true --quiet= -e=a --port=x --quiet= -e=b --verbose=false --all= " +
+		"residual=-v residual=file"
+	if e := g.Err(); e != nil || strings.Join(got, " ") != want {
+		t.Errorf("wrong result %v: %s", e, strings.Join(got, " "))
+	}
+	// Nothing was stored or checked.
+	if verbose || port != 0 || handled || oVerbose.Seen || oQuiet.Seen ||
+		len(opts.Occurrences()) != 0 {
+		t.Errorf("values were stored")
+	}
+
+	// Breaking out of the loop.
+	got = nil
+	for opt := range g.All() {
+		if opt == oPort {
+			break
+		}
+		got = append(got, opt.name())
+	}
+	if strings.Join(got, " ") != "--verbose --quiet -e" || g.Err() != nil {
+		t.Errorf("wrong result: %v", got)
+	}
+	for opt := range g.All() {
+		if opt == nil {
+			break
+		}
+	}
+
+	// Errors stop the iteration.
+	g = opts.Getopt([]string{"-v", "--bogus", "-q", "file"})
+	got = nil
+	for opt, val := range g.All() {
+		got = append(got, opt.name()+"="+val)
+	}
+	if strings.Join(got, " ") != "--verbose=true" || !errors.Is(g.Err(), ErrNoSuchOption) {
+		t.Errorf("wrong result %v: %v", g.Err(), got)
+	}
+	g = opts.Getopt([]string{"-v", "-e"})
+	for range g.All() {
+	}
+	mustFailAs(t, g.Err(), ErrOptionRequiresValue)
+
+	// Unless they are being collected.
+	opts.CollectErrors = true
+	g = opts.Getopt([]string{"-xv", "--bogus", "-q", "file"})
+	got = nil
+	for opt, val := range g.All() {
+		got = append(got, opt.name()+"="+val)
+	}
+	var pes ParseErrors
+	if strings.Join(got, " ") != "--verbose=true --quiet=" ||
+		!errors.As(g.Err(), &pes) || len(pes) != 2 {
+		t.Errorf("wrong result %v: %v", g.Err(), got)
+	}
+
+	// Parsing is unaffected.
+	opts.CollectErrors = false
+	_ = mustParse(t, opts, []string{"-v", "file"})
+	if !verbose {
+		t.Errorf("not parsed")
+	}
+}
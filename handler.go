@@ -0,0 +1,86 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+)
+
+// HandlerContext is passed to Option.HandleContext, and describes the
+// occurrence of the option being handled.
+type HandlerContext struct {
+	// Option is the option that was found.
+	Option *Option
+
+	// Name is the option as it was spelled, for example "-v"
+	// or "--verbose".
+	Name string
+
+	// Value is the value of the option, after any normalization.
+	// It is empty for options that do not take a value.
+	Value string
+
+	// Index is the index of the argument holding the option, within
+	// the arguments passed to Parse.  It is -1 if the option was not
+	// given in the arguments, for example when applied by Options.Set.
+	Index int
+
+	// Count is the number of earlier occurrences of the option, so
+	// it is zero the first time the option is seen.
+	Count int
+
+	ctx   context.Context
+	p     *parser
+	value place // where the value is in the arguments
+}
+
+// Context returns the context passed to Options.ParseContext, or the
+// background context if Options.Parse was used.
+func (hc *HandlerContext) Context() context.Context {
+	return hc.ctx
+}
+
+// Args returns the arguments that have not been processed yet.
+func (hc *HandlerContext) Args() []string {
+	if hc.p == nil {
+		return nil
+	}
+	return hc.p.args[hc.p.pos:]
+}
+
+// Next consumes the next argument, so that it is not processed by
+// the parser, and returns it.  If there are no more arguments, it
+// returns false.  If the option is part of a cluster of short options,
+// then the rest of the cluster is processed after any arguments
+// consumed here.
+func (hc *HandlerContext) Next() (string, bool) {
+	if hc.p == nil || hc.p.pos >= len(hc.p.args) {
+		return "", false
+	}
+	arg := hc.p.args[hc.p.pos]
+	hc.p.pos++
+	return arg, true
+}
+
+// Stop ends option processing after this option.  Any arguments
+// that have not been processed are returned by Parse as residual
+// arguments, even if they look like options.
+func (hc *HandlerContext) Stop() {
+	if hc.p != nil {
+		hc.p.stop = true
+	}
+}
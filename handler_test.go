@@ -0,0 +1,152 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHandlerContext(t *testing.T) {
+	opts := &Options{}
+	var calls []string
+	shared := func(hc *HandlerContext) error {
+		calls = append(calls, fmt.Sprintf("%s=%s@%d#%d",
+			hc.Name, hc.Value, hc.Index, hc.Count))
+		return nil
+	}
+	oV := &Option{Short: 'v', Long: "verbose", HandleContext: shared}
+	oL := &Option{Short: 'l', Long: "level", HasArg: true, HandleContext: shared}
+	if e := opts.Add(oV, oL); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	args := mustParse(t, opts, []string{"-v", "--verbose", "-vl3", "--level=4", "x"})
+	if len(args) != 1 || args[0] != "x" {
+		t.Fatal("oops")
+	}
+	want := "-v=@0#0|--verbose=@1#1|-v=@2#2|-l=3@2#0|--level=4@3#1"
+	if got := strings.Join(calls, "|"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHandlerContext_Next(t *testing.T) {
+	opts := &Options{}
+	var cmd []string
+	oExec := &Option{
+		Long:  "exec",
+		Short: 'e',
+		HandleContext: func(hc *HandlerContext) error {
+			for {
+				arg, ok := hc.Next()
+				if !ok || arg == ";" {
+					return nil
+				}
+				cmd = append(cmd, arg)
+			}
+		},
+	}
+	oV := &Option{Short: 'v'}
+	if e := opts.Add(oExec, oV); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	args := mustParse(t, opts, []string{"--exec", "ls", "-l", ";", "-v", "x"})
+	if len(args) != 1 || args[0] != "x" || !oV.Seen {
+		t.Fatal("oops")
+	}
+	if strings.Join(cmd, " ") != "ls -l" {
+		t.Errorf("wrong command: %v", cmd)
+	}
+
+	// The rest of a cluster is processed after consumed arguments.
+	opts.Reset()
+	cmd = nil
+	args = mustParse(t, opts, []string{"-ev", "rm", ";", "y"})
+	if len(args) != 1 || args[0] != "y" || !oV.Seen {
+		t.Fatal("oops")
+	}
+	if strings.Join(cmd, " ") != "rm" {
+		t.Errorf("wrong command: %v", cmd)
+	}
+}
+
+func TestHandlerContext_Stop(t *testing.T) {
+	opts := &Options{}
+	type key struct{}
+	var ctxVal interface{}
+	var rest []string
+	oStop := &Option{
+		Short: 's',
+		HandleContext: func(hc *HandlerContext) error {
+			ctxVal = hc.Context().Value(key{})
+			rest = hc.Args()
+			hc.Stop()
+			return nil
+		},
+	}
+	oV := &Option{Short: 'v'}
+	if e := opts.Add(oStop, oV); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	ctx := context.WithValue(context.Background(), key{}, "hello")
+	args, e := opts.ParseContext(ctx, []string{"-s", "-v", "x"})
+	if e != nil {
+		t.Fatalf("parse fail: %v", e)
+	}
+	if len(args) != 2 || args[0] != "-v" || args[1] != "x" || oV.Seen {
+		t.Fatalf("did not stop: %v", args)
+	}
+	if ctxVal != "hello" || len(rest) != 2 {
+		t.Errorf("wrong context or args")
+	}
+
+	opts.Reset()
+	args = mustParse(t, opts, []string{"-sv", "x"})
+	if len(args) != 2 || args[0] != "-v" || args[1] != "x" || oV.Seen {
+		t.Fatalf("did not stop in cluster: %v", args)
+	}
+}
+
+func TestHandlerContext_Set(t *testing.T) {
+	opts := &Options{}
+	var hc *HandlerContext
+	o := &Option{
+		Long:   "name",
+		HasArg: true,
+		HandleContext: func(c *HandlerContext) error {
+			hc = c
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+	if e := opts.Set("name", "bob", Source{Kind: SourceEnv}); e != nil {
+		t.Fatalf("set fail: %v", e)
+	}
+	if hc.Option != o || hc.Index != -1 || hc.Value != "bob" ||
+		hc.Context() == nil || hc.Args() != nil {
+		t.Errorf("wrong context")
+	}
+	if _, ok := hc.Next(); ok {
+		t.Errorf("next should fail")
+	}
+	hc.Stop()
+}
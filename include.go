@@ -0,0 +1,85 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Include adds the options of another set, so that a package can
+// contribute its own options to a program.  The long names of the
+// included options are given the prefix, such as "tls-" (so that
+// --cert becomes --tls-cert).  Short names are not changed.  If the
+// title is not empty, the included options are listed in their own
+// section of the help, with that title.
+//
+// It is an error (ErrDuplicateOption) if any of the names collide with
+// options that were already added, in which case nothing is included.
+//
+// The BeforeParse, AfterParse and Validate functions of the included
+// set are run by Parse, before those of this set.  Options added to the
+// included set later are not included.  An option can only belong to
+// one program, as its displayed name includes the prefix.
+func (o *Options) Include(sub *Options, prefix, title string) error {
+	o.init()
+	sub.init()
+	if sub == o {
+		return mkErr(ErrDuplicateOption, "cannot include options in themselves")
+	}
+	prefix = strings.TrimPrefix(prefix, "--")
+	if strings.ContainsRune(prefix, '=') ||
+		strings.IndexFunc(prefix, unicode.IsSpace) >= 0 {
+		return mkErr(ErrBadOption, fmt.Sprintf("invalid prefix %q", prefix))
+	}
+	long := map[string]bool{}
+	for _, opt := range sub.allOpts {
+		if o.index(opt) >= 0 {
+			return mkErr(ErrDuplicateOption, opt.name())
+		}
+		if name := opt.longName(); name != "" {
+			if o.longOpts[prefix+name] != nil || long[prefix+name] {
+				return mkErr(ErrDuplicateOption, "--"+prefix+name)
+			}
+			long[prefix+name] = true
+		}
+		if opt.Short != 0 && o.shortOpts[opt.Short] != nil {
+			return mkErr(ErrDuplicateOption, "-"+string(opt.Short))
+		}
+	}
+	for _, opt := range sub.allOpts {
+		opt.prefix = prefix + opt.prefix
+		if opt.section == "" {
+			opt.section = title
+		}
+		o.mapNames(opt)
+		o.allOpts = append(o.allOpts, opt)
+	}
+	o.included = append(o.included, sub)
+	return nil
+}
+
+// sets returns the sets whose hooks should be run, which are the
+// included sets (and the sets they include), followed by this one.
+func (o *Options) sets() []*Options {
+	var all []*Options
+	for _, sub := range o.included {
+		all = append(all, sub.sets()...)
+	}
+	return append(all, o)
+}
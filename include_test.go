@@ -0,0 +1,182 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Include(t *testing.T) {
+	var order []string
+	var cert, key string
+	tls := &Options{
+		BeforeParse: func() error { order = append(order, "tls before"); return nil },
+		AfterParse:  func([]string) error { order = append(order, "tls after"); return nil },
+		Validate: func() error {
+			order = append(order, "tls validate")
+			if key != "" && cert == "" {
+				return errors.New("key without cert")
+			}
+			return nil
+		},
+	}
+	handled := ""
+	oCert := &Option{Long: "cert", ArgP: &cert, Help: "Certificate"}
+	oKey := &Option{Long: "key", ArgP: &key, Help: "Key",
+		Handle: func(v string) error { handled = v; return nil }}
+	if e := tls.Add(oCert, oKey); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	var verbose bool
+	opts := &Options{
+		BeforeParse: func() error { order = append(order, "main before"); return nil },
+		AfterParse:  func([]string) error { order = append(order, "main after"); return nil },
+		Validate:    func() error { order = append(order, "main validate"); return nil },
+	}
+	mustAdd(t, opts, &Option{Long: "verbose", Short: 'v', ArgP: &verbose, Help: "Verbose"})
+	if e := opts.Include(tls, "tls-", "TLS options"); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+
+	_ = mustParse(t, opts, []string{"--tls-cert", "c.pem", "--tls-key=k.pem", "-v"})
+	if cert != "c.pem" || key != "k.pem" || handled != "k.pem" || !verbose {
+		t.Errorf("wrong values %q %q %q", cert, key, handled)
+	}
+	if strings.Join(order, ", ") != "tls before, main before, "+
+		"tls after, main after, tls validate, main validate" {
+		t.Errorf("wrong order: %v", order)
+	}
+	if opts.Lookup("tls-cert") != oCert || opts.Lookup("cert") != nil {
+		t.Errorf("lookup wrong")
+	}
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"--cert", "c.pem"})
+	mustFailAs(t, e, ErrNoSuchOption)
+
+	opts.Reset()
+	cert = ""
+	_, e = opts.Parse([]string{"--tls-key", "k.pem"})
+	mustFailAs(t, e, ErrInvalidOptions)
+
+	oCert.MaxCount = 1
+	opts.Reset()
+	_, e = opts.Parse([]string{"--tls-cert", "a", "--tls-cert", "b"})
+	mustFailAs(t, e, ErrRepeatedOption)
+	if e != nil && !strings.Contains(e.Error(), "--tls-cert") {
+		t.Errorf("prefix not in error: %v", e)
+	}
+	oCert.MaxCount = 0
+
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--tls-cert", "c.pem"})
+	if !strings.Contains(opts.Config(), "--tls-cert    c.pem") {
+		t.Errorf("prefix not in config:\n%s", opts.Config())
+	}
+
+	good := `Options:
+  -v, --verbose     Verbose
+
+TLS options:
+  --tls-cert ARG    Certificate
+  --tls-key ARG     Key
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
+
+func TestOptions_IncludeCollisions(t *testing.T) {
+	sub := &Options{}
+	mustAdd(t, sub, &Option{Long: "cert", Short: 'c'})
+	mustAdd(t, sub, &Option{Long: "key"})
+
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "tls-key"})
+	mustFailAs(t, opts.Include(sub, "tls-", ""), ErrDuplicateOption)
+	if opts.Lookup("tls-cert") != nil || len(opts.All()) != 1 {
+		t.Errorf("partly included")
+	}
+
+	opts = &Options{}
+	mustAdd(t, opts, &Option{Short: 'c'})
+	mustFailAs(t, opts.Include(sub, "tls-", ""), ErrDuplicateOption)
+
+	opts = &Options{}
+	if e := opts.Include(sub, "--a-", ""); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	mustFailAs(t, opts.Include(sub, "b-", ""), ErrDuplicateOption)
+	mustFailAs(t, opts.Include(opts, "b-", ""), ErrDuplicateOption)
+	mustFailAs(t, opts.Include(&Options{}, "b c-", ""), ErrBadOption)
+	if opts.Lookup("a-cert") == nil {
+		t.Errorf("prefix with dashes not handled")
+	}
+
+	// Without a prefix or title, options are merged.
+	other := &Options{}
+	mustAdd(t, other, &Option{Long: "debug", Help: "Debug"})
+	if e := opts.Include(other, "", ""); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	good := `Options:
+  --debug    Debug
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
+
+func TestOptions_IncludeNested(t *testing.T) {
+	var level string
+	inner := &Options{}
+	mustAdd(t, inner, &Option{Long: "level", ArgP: &level, Help: "Level"})
+	validated := false
+	middle := &Options{Validate: func() error { validated = true; return nil }}
+	if e := middle.Include(inner, "log-", ""); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	opts := &Options{}
+	if e := opts.Include(middle, "x-", "Extra"); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"--x-log-level", "debug"})
+	if level != "debug" || !validated {
+		t.Errorf("nested include not handled")
+	}
+	good := `Extra:
+  --x-log-level ARG    Level
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+
+	// Replacing an included option keeps its prefix and section.
+	repl := &Option{Long: "level", ArgP: &level, ArgName: "LVL", Help: "Log level"}
+	if e := opts.Replace(opts.Lookup("x-log-level"), repl); e != nil {
+		t.Fatalf("replace failed: %v", e)
+	}
+	good = `Extra:
+  --x-log-level LVL    Log level
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
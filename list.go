@@ -0,0 +1,165 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// List is used as an ArgP for options that take several values in
+// one argument, such as --tags a,b,c.  Each occurrence of the option
+// appends to the list.  As with CSV, an item can be enclosed in double
+// quotes, so that it can contain the separator; a double quote within
+// such an item is written as two double quotes.  An empty argument
+// adds nothing.
+type List struct {
+	// Target is a pointer to a slice, for example *[]string or *[]int.
+	// Items are converted using the same rules as for ArgP.
+	Target interface{}
+
+	// Separator separates the items.  If empty, "," is used.
+	Separator string
+}
+
+func (l *List) sep() string {
+	if l.Separator == "" {
+		return ","
+	}
+	return l.Separator
+}
+
+// splitList splits a list into its items, removing any quotes.
+func splitList(val, sep string) ([]string, error) {
+	var items []string
+	if val == "" {
+		return items, nil
+	}
+	for i := 0; ; {
+		item := strings.Builder{}
+		if strings.HasPrefix(val[i:], `"`) {
+			start := i
+			for i++; ; i++ {
+				if i >= len(val) {
+					return nil, &posError{
+						msg: fmt.Sprintf("unterminated quote in %q", val),
+						val: val,
+						pos: start,
+					}
+				}
+				if val[i] != '"' {
+					item.WriteByte(val[i])
+					continue
+				}
+				if i++; !strings.HasPrefix(val[i:], `"`) {
+					break
+				}
+				item.WriteByte('"')
+			}
+			if i < len(val) && !strings.HasPrefix(val[i:], sep) {
+				return nil, &posError{
+					msg: fmt.Sprintf("missing separator after quote in %q", val),
+					val: val,
+					pos: i,
+				}
+			}
+		} else {
+			end := strings.Index(val[i:], sep)
+			if end < 0 {
+				end = len(val) - i
+			}
+			item.WriteString(val[i : i+end])
+			i += end
+		}
+		items = append(items, item.String())
+		if i >= len(val) {
+			return items, nil
+		}
+		i += len(sep)
+	}
+}
+
+// quoteList joins items into a list, quoting them where needed, so
+// that splitList gives the same items back.
+func quoteList(items []string, sep string) string {
+	quoted := make([]string, 0, len(items))
+	for _, item := range items {
+		if item == "" || strings.Contains(item, sep) ||
+			strings.HasPrefix(item, `"`) {
+			item = `"` + strings.ReplaceAll(item, `"`, `""`) + `"`
+		}
+		quoted = append(quoted, item)
+	}
+	return strings.Join(quoted, sep)
+}
+
+func (l *List) convert(val string, opt *Option) (interface{}, func(), error) {
+	if !isSlice(l.Target) {
+		return nil, nil, fmt.Errorf("list target %T is not a slice", l.Target)
+	}
+	items, e := splitList(val, l.sep())
+	if e != nil {
+		return nil, nil, e
+	}
+	sv := reflect.ValueOf(l.Target).Elem()
+	add := reflect.MakeSlice(sv.Type(), 0, len(items))
+	for _, item := range items {
+		elem := reflect.New(sv.Type().Elem())
+		if e := setValue(elem.Interface(), item, opt.Base); e != nil {
+			return nil, nil, fmt.Errorf("item %q: %v", item, cause(e))
+		}
+		add = reflect.Append(add, elem.Elem())
+	}
+	commit := func() {
+		if opt.Repeat == RepeatLast {
+			sv.Set(reflect.MakeSlice(sv.Type(), 0, add.Len()))
+		}
+		sv.Set(reflect.AppendSlice(sv, add))
+	}
+	return add.Interface(), commit, nil
+}
+
+// String returns the items, quoted where needed and joined with
+// the separator.
+func (l *List) String() string {
+	return l.format(0)
+}
+
+// format is like String, but writes integers in the given base.
+func (l *List) format(base int) string {
+	if !isSlice(l.Target) {
+		return ""
+	}
+	sv := reflect.ValueOf(l.Target).Elem()
+	items := make([]string, 0, sv.Len())
+	for i := 0; i < sv.Len(); i++ {
+		items = append(items, formatArg(sv.Index(i).Addr().Interface(), base))
+	}
+	return quoteList(items, l.sep())
+}
+
+func (l *List) hint() (string, string) {
+	name := "VALUE"
+	if isSlice(l.Target) {
+		elem := reflect.New(reflect.TypeOf(l.Target).Elem().Elem())
+		if n, _ := valueHint(elem.Interface()); n != "" {
+			name = n
+		}
+	}
+	return name + l.sep() + "...", ""
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	opts := &Options{}
+	var tags []string
+	l := &List{Target: &tags}
+	mustAdd(t, opts, &Option{Long: "tags", Short: 't', ArgP: l})
+
+	for _, c := range []struct {
+		in   string
+		want []string
+	}{
+		{"a,b,c", []string{"a", "b", "c"}},
+		{"a", []string{"a"}},
+		{"", nil},
+		{"a,,b", []string{"a", "", "b"}},
+		{",", []string{"", ""}},
+		{`"a,b",c`, []string{"a,b", "c"}},
+		{`"say ""hi""",x`, []string{`say "hi"`, "x"}},
+		{`a"b,c`, []string{`a"b`, "c"}},
+		{`"",""`, []string{"", ""}},
+	} {
+		tags = nil
+		opts.Reset()
+		_ = mustParse(t, opts, []string{"--tags", c.in})
+		if !reflect.DeepEqual(tags, c.want) {
+			t.Errorf("%s: got %q, want %q", c.in, tags, c.want)
+		}
+		again, e := splitList(l.String(), ",")
+		if e != nil || !reflect.DeepEqual(again, c.want) {
+			t.Errorf("%s: did not round trip: %q", c.in, l.String())
+		}
+	}
+
+	tags = nil
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-t", "a,b", "--tags", "c"})
+	if !reflect.DeepEqual(tags, []string{"a", "b", "c"}) {
+		t.Errorf("did not accumulate: %q", tags)
+	}
+
+	for _, c := range []struct {
+		in  string
+		msg string
+	}{
+		{`a,"b`, `unterminated quote in "a,\"b" at position 3`},
+		{`"a"b,c`, `missing separator after quote in "\"a\"b,c" at position 4`},
+	} {
+		opts.Reset()
+		_, e := opts.Parse([]string{"--tags", c.in})
+		mustFailAs(t, e, ErrParsingValue)
+		if e != nil && e.Error() != "failure parsing option value: --tags: "+c.msg {
+			t.Errorf("%s: wrong message: %v", c.in, e)
+		}
+	}
+}
+
+func TestList_Types(t *testing.T) {
+	opts := &Options{}
+	var ports []uint16
+	var globs []Glob
+	e := opts.Add(
+		&Option{Long: "ports", ArgP: &List{Target: &ports, Separator: ":"}},
+		&Option{Long: "skip", ArgP: &List{Target: &globs}, Repeat: RepeatLast},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{
+		"--ports", "80:0x1bb", "--skip", "*.o,*.a", "--skip", "*~",
+	})
+	if !reflect.DeepEqual(ports, []uint16{80, 443}) {
+		t.Errorf("wrong ports %v", ports)
+	}
+	if !reflect.DeepEqual(globs, []Glob{"*~"}) {
+		t.Errorf("wrong globs %q", globs)
+	}
+
+	ports = nil
+	opts.Reset()
+	_, e = opts.Parse([]string{"--ports", "80:http"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e != nil && e.Error() != `failure parsing option value: --ports: `+
+		`item "http": invalid syntax` {
+		t.Errorf("wrong message: %v", e)
+	}
+	if ports != nil {
+		t.Errorf("ports changed by failed parse: %v", ports)
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--skip", "a,[b"})
+
+	bad := &Options{}
+	var notSlice string
+	mustFailAs(t, bad.Add(&Option{Long: "bad", ArgP: &List{Target: &notSlice}}), ErrBadOption)
+}
+
+func TestList_Help(t *testing.T) {
+	opts := &Options{}
+	var a []string
+	var b []Glob
+	e := opts.Add(
+		&Option{Long: "tags", ArgP: &List{Target: &a}, Help: "Tags"},
+		&Option{Long: "skip", ArgP: &List{Target: &b, Separator: ";"}, Help: "Skip"},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --tags VALUE,...    Tags
+  --skip GLOB;...     Skip
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
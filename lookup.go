@@ -0,0 +1,157 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+)
+
+// Lookup returns the option with the given long name, or nil if
+// there is none.
+func (o *Options) Lookup(long string) *Option {
+	o.init()
+	return o.longOpts[long]
+}
+
+// LookupShort returns the option with the given short name, or nil if
+// there is none.
+func (o *Options) LookupShort(short rune) *Option {
+	o.init()
+	return o.shortOpts[short]
+}
+
+// All returns the options that have been added, in the order they
+// were added.  The slice is a copy, so changing it has no effect.
+func (o *Options) All() []*Option {
+	return append([]*Option(nil), o.allOpts...)
+}
+
+// index returns the position of the option, or -1 if it has not
+// been added.
+func (o *Options) index(opt *Option) int {
+	for i, a := range o.allOpts {
+		if a == opt {
+			return i
+		}
+	}
+	return -1
+}
+
+// unmap removes the option from the lookup maps.  The maps are
+// searched, in case the names were changed after the option was added.
+func (o *Options) unmap(opt *Option) {
+	for name, a := range o.longOpts {
+		if a == opt {
+			delete(o.longOpts, name)
+		}
+	}
+	for short, a := range o.shortOpts {
+		if a == opt {
+			delete(o.shortOpts, short)
+		}
+	}
+}
+
+// Remove removes an option that was added.  It is an error if the
+// option was not added, or if another option still refers to it
+// in Requires, Implies or Conflicts.
+func (o *Options) Remove(opt *Option) error {
+	o.init()
+	i := o.index(opt)
+	if i < 0 {
+		return mkErr(ErrNoSuchOption, opt.name())
+	}
+	for _, a := range o.allOpts {
+		if a != opt && a.refersTo(opt) {
+			return mkErr(ErrBadRelation, fmt.Sprintf(
+				"%s refers to %s", a.name(), opt.name()))
+		}
+	}
+	o.unmap(opt)
+	o.allOpts = append(o.allOpts[:i], o.allOpts[i+1:]...)
+	return nil
+}
+
+// Replace replaces an option that was added with another, which takes
+// its place in the order of options, and any prefix and help section
+// from Include.  References to the old option in the relations of
+// other options are changed to refer to the new one.  The new option
+// is checked as for Add, and if there is an error nothing is changed.
+func (o *Options) Replace(old, opt *Option) error {
+	o.init()
+	i := o.index(old)
+	if i < 0 {
+		return mkErr(ErrNoSuchOption, old.name())
+	}
+	if opt != old && o.index(opt) >= 0 {
+		return mkErr(ErrDuplicateOption, opt.name())
+	}
+	// The option is prepared before it is checked, so keep a copy to
+	// restore if any check fails.
+	copied := *opt
+	fail := func(e error) error {
+		*opt = copied
+		return e
+	}
+	opt.prepare()
+	if e := opt.check(); e != nil {
+		return fail(e)
+	}
+	opt.prefix, opt.section = old.prefix, old.section
+	long := opt.longName()
+	if a := o.longOpts[long]; long != "" && a != nil && a != old {
+		return fail(mkErr(ErrDuplicateOption, "--"+long))
+	}
+	if a := o.shortOpts[opt.Short]; opt.Short != 0 && a != nil && a != old {
+		return fail(mkErr(ErrDuplicateOption, "-"+string(opt.Short)))
+	}
+
+	// Save what we change, so that we can undo it.
+	type relations struct{ requires, implies, conflicts []*Option }
+	saved := make(map[*Option]relations)
+	for _, a := range o.allOpts {
+		saved[a] = relations{a.Requires, a.Implies, a.Conflicts}
+		a.replaceRef(old, opt)
+	}
+	o.unmap(old)
+	o.allOpts[i] = opt
+	o.mapNames(opt)
+	set := optionSet(o.allOpts)
+	for _, a := range o.allOpts {
+		if e := a.checkRelations(set); e != nil {
+			o.unmap(opt)
+			o.allOpts[i] = old
+			o.mapNames(old)
+			for a, r := range saved {
+				a.Requires, a.Implies, a.Conflicts = r.requires, r.implies, r.conflicts
+			}
+			return fail(e)
+		}
+	}
+	opt.reset()
+	return nil
+}
+
+// mapNames adds the option to the lookup maps.
+func (o *Options) mapNames(opt *Option) {
+	if long := opt.longName(); long != "" {
+		o.longOpts[long] = opt
+	}
+	if opt.Short != 0 {
+		o.shortOpts[opt.Short] = opt
+	}
+}
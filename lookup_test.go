@@ -0,0 +1,169 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestOptions_Lookup(t *testing.T) {
+	opts := &Options{}
+	if opts.Lookup("x") != nil || opts.LookupShort('x') != nil || len(opts.All()) != 0 {
+		t.Errorf("empty options not empty")
+	}
+	oA := &Option{Long: "alpha", Short: 'a'}
+	oB := &Option{Long: "beta"}
+	oC := &Option{Short: 'c'}
+	if e := opts.Add(oA, oB, oC); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	if opts.Lookup("alpha") != oA || opts.LookupShort('a') != oA ||
+		opts.Lookup("beta") != oB || opts.LookupShort('c') != oC ||
+		opts.Lookup("c") != nil || opts.LookupShort('b') != nil {
+		t.Errorf("lookup failed")
+	}
+	all := opts.All()
+	if len(all) != 3 || all[0] != oA || all[1] != oB || all[2] != oC {
+		t.Errorf("wrong order: %v", all)
+	}
+	all[0] = nil
+	if opts.All()[0] != oA {
+		t.Errorf("All did not copy")
+	}
+}
+
+func TestOptions_Remove(t *testing.T) {
+	opts := &Options{}
+	oA := &Option{Long: "alpha", Short: 'a'}
+	oB := &Option{Long: "beta", Short: 'b'}
+	oC := &Option{Long: "gamma", Requires: []*Option{oB}}
+	if e := opts.Add(oA, oB, oC); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	mustFailAs(t, opts.Remove(oB), ErrBadRelation)
+	mustFailAs(t, opts.Remove(&Option{Long: "delta"}), ErrNoSuchOption)
+
+	if e := opts.Remove(oA); e != nil {
+		t.Fatalf("remove failed: %v", e)
+	}
+	if opts.Lookup("alpha") != nil || opts.LookupShort('a') != nil {
+		t.Errorf("still found after remove")
+	}
+	if all := opts.All(); len(all) != 2 || all[0] != oB || all[1] != oC {
+		t.Errorf("wrong options: %v", all)
+	}
+	_, e := opts.Parse([]string{"-a"})
+	mustFailAs(t, e, ErrNoSuchOption)
+	mustFailAs(t, opts.Remove(oA), ErrNoSuchOption)
+
+	// Names may be reused after removal.
+	if e := opts.Add(&Option{Long: "alpha", Short: 'a'}); e != nil {
+		t.Errorf("cannot add again: %v", e)
+	}
+
+	// A renamed option is still removed from the maps.
+	oB.Long = "renamed"
+	if e := opts.Remove(oC); e != nil {
+		t.Fatalf("remove failed: %v", e)
+	}
+	if e := opts.Remove(oB); e != nil {
+		t.Fatalf("remove failed: %v", e)
+	}
+	if opts.LookupShort('b') != nil || len(opts.All()) != 1 {
+		t.Errorf("not removed")
+	}
+	if e := opts.Add(&Option{Long: "beta"}); e != nil {
+		t.Errorf("stale map entry: %v", e)
+	}
+}
+
+func TestOptions_Replace(t *testing.T) {
+	opts := &Options{}
+	var level int
+	oA := &Option{Long: "alpha", Short: 'a'}
+	oB := &Option{Long: "beta", Short: 'b'}
+	oC := &Option{Long: "gamma", Requires: []*Option{oB}}
+	oD := &Option{Long: "delta", Conflicts: []*Option{oA}}
+	if e := opts.Add(oA, oB, oC, oD); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	requires := oC.Requires
+
+	oB2 := &Option{Long: "beta2", Short: 'b', ArgP: &level}
+	if e := opts.Replace(oB, oB2); e != nil {
+		t.Fatalf("replace failed: %v", e)
+	}
+	if opts.Lookup("beta") != nil || opts.Lookup("beta2") != oB2 ||
+		opts.LookupShort('b') != oB2 || !oB2.HasArg {
+		t.Errorf("lookup wrong after replace")
+	}
+	if all := opts.All(); len(all) != 4 || all[1] != oB2 {
+		t.Errorf("wrong order: %v", all)
+	}
+	if oC.Requires[0] != oB2 || requires[0] != oB {
+		t.Errorf("references not replaced")
+	}
+	_ = mustParse(t, opts, []string{"--gamma", "-b", "3"})
+	if level != 3 {
+		t.Errorf("replacement not used")
+	}
+
+	mustFailAs(t, opts.Replace(oB, &Option{Long: "x"}), ErrNoSuchOption)
+	mustFailAs(t, opts.Replace(oB2, &Option{Long: "alpha"}), ErrDuplicateOption)
+	mustFailAs(t, opts.Replace(oB2, &Option{Short: 'a'}), ErrDuplicateOption)
+	mustFailAs(t, opts.Replace(oB2, oA), ErrDuplicateOption)
+	mustFailAs(t, opts.Replace(oB2, &Option{}), ErrShortAndLongEmpty)
+
+	// A replacement that breaks a relation is undone.
+	bad := &Option{Long: "beta3", Implies: []*Option{oA, oD}}
+	mustFailAs(t, opts.Replace(oB2, bad), ErrBadRelation)
+	if opts.Lookup("beta2") != oB2 || opts.Lookup("beta3") != nil ||
+		opts.All()[1] != oB2 || oC.Requires[0] != oB2 {
+		t.Errorf("failed replace not undone")
+	}
+
+	// Nor is the new option changed.
+	var pw Secret
+	sub := &Options{}
+	oCert := &Option{Long: "cert"}
+	mustAdd(t, sub, oCert)
+	if e := opts.Include(sub, "tls-", "TLS"); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	for _, c := range []struct {
+		opt  *Option
+		code err
+	}{
+		{&Option{Short: 'a', ArgP: &pw}, ErrDuplicateOption},
+		{&Option{Long: "x", ArgP: &pw, Implies: []*Option{oA, oD}}, ErrBadRelation},
+	} {
+		mustFailAs(t, opts.Replace(oCert, c.opt), c.code)
+		if c.opt.HasArg || c.opt.Secret || c.opt.prefix != "" || c.opt.section != "" {
+			t.Errorf("%s: option changed", c.opt.name())
+		}
+	}
+
+	// Replacing with the same option is allowed, and picks up new names.
+	oB2.Long = "beta4"
+	if e := opts.Replace(oB2, oB2); e != nil {
+		t.Fatalf("replace failed: %v", e)
+	}
+	if opts.Lookup("beta2") != nil || opts.Lookup("beta4") != oB2 {
+		t.Errorf("names not updated")
+	}
+}
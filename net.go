@@ -0,0 +1,192 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HostPort is a host and port, such as example.com:80 or [::1]:8080.
+// A *HostPort can be used as ArgP.  The host may be a name, an IPv4
+// or IPv6 address (in brackets if a port is given), or empty to mean
+// any address.  Names are checked for syntax, but never looked up.
+type HostPort struct {
+	// Host is the host name or address, without brackets.
+	Host string
+
+	// Port is the port number.
+	Port uint16
+
+	// DefaultPort, if not zero, is used when no port is given.
+	// Otherwise a port is required.
+	DefaultPort uint16
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (hp *HostPort) UnmarshalText(text []byte) error {
+	val := string(text)
+	if val == "" {
+		return errors.New("empty address")
+	}
+	host := val
+	port := ""
+	hasPort := false
+	switch {
+	case strings.HasPrefix(val, "["):
+		end := strings.Index(val, "]")
+		if end < 0 {
+			return fmt.Errorf("missing ']' in %q", val)
+		}
+		host = val[1:end]
+		if rest := val[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return fmt.Errorf("invalid address %q", val)
+			}
+			port, hasPort = rest[1:], true
+		}
+		if a, e := netip.ParseAddr(host); e != nil || !a.Is6() {
+			return fmt.Errorf("invalid IPv6 address %q", host)
+		}
+	case strings.Count(val, ":") > 1:
+		// An IPv6 address without brackets, so without a port.
+		if a, e := netip.ParseAddr(val); e != nil || !a.Is6() {
+			return fmt.Errorf("invalid address %q (IPv6 addresses "+
+				"with ports need brackets)", val)
+		}
+	case strings.Contains(val, ":"):
+		i := strings.LastIndex(val, ":")
+		host, port, hasPort = val[:i], val[i+1:], true
+	}
+	if host != "" && !validHost(host) {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	p := hp.DefaultPort
+	if hasPort {
+		n, e := strconv.ParseUint(port, 10, 16)
+		if e != nil {
+			return fmt.Errorf("invalid port %q (must be 0 to 65535)", port)
+		}
+		p = uint16(n)
+	} else if p == 0 {
+		return fmt.Errorf("missing port in %q", val)
+	}
+	hp.Host = host
+	hp.Port = p
+	return nil
+}
+
+// validHost checks that the host is an IP address, or a syntactically
+// valid host name.
+func validHost(host string) bool {
+	if _, e := netip.ParseAddr(host); e == nil {
+		return true
+	}
+	name := strings.TrimSuffix(host, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 ||
+			label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			case c >= '0' && c <= '9', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (hp *HostPort) String() string {
+	return net.JoinHostPort(hp.Host, strconv.Itoa(int(hp.Port)))
+}
+
+func (hp *HostPort) hint() (string, string) {
+	if hp.DefaultPort != 0 {
+		return "HOST[:PORT]", ""
+	}
+	return "HOST:PORT", ""
+}
+
+// URL is used as an ArgP for options that take an absolute URL,
+// optionally restricted to some schemes.  (A *url.URL can also be used
+// directly as ArgP, accepting any absolute URL.)
+type URL struct {
+	// Target is where the URL is stored.
+	Target *url.URL
+
+	// Schemes, if not empty, lists the schemes that are permitted,
+	// such as "https".  Schemes are not case sensitive.
+	Schemes []string
+}
+
+func (u *URL) parse(val string) (*url.URL, error) {
+	v, e := url.Parse(val)
+	if e != nil {
+		var ue *url.Error
+		if errors.As(e, &ue) {
+			e = ue.Err
+		}
+		return nil, fmt.Errorf("invalid URL %q: %v", val, e)
+	}
+	if v.Scheme == "" {
+		return nil, fmt.Errorf("URL %q has no scheme", val)
+	}
+	if len(u.Schemes) == 0 {
+		return v, nil
+	}
+	for _, s := range u.Schemes {
+		if strings.EqualFold(s, v.Scheme) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("URL scheme %q not permitted (permitted: %s)",
+		v.Scheme, strings.Join(u.Schemes, ", "))
+}
+
+func (u *URL) convert(val string, _ *Option) (interface{}, func(), error) {
+	v, e := u.parse(val)
+	if e != nil {
+		return nil, nil, e
+	}
+	return v, func() { *u.Target = *v }, nil
+}
+
+func (u *URL) hint() (string, string) {
+	if len(u.Schemes) == 0 {
+		return "URL", ""
+	}
+	return "URL", strings.Join(u.Schemes, " or ") + " URL"
+}
+
+func (u *URL) String() string {
+	if u.Target == nil {
+		return ""
+	}
+	return u.Target.String()
+}
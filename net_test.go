@@ -0,0 +1,181 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestHostPort(t *testing.T) {
+	opts := &Options{}
+	val := &HostPort{DefaultPort: 80}
+	mustAdd(t, opts, &Option{Long: "listen", ArgP: val})
+
+	for _, c := range []struct {
+		in   string
+		host string
+		port uint16
+		str  string
+	}{
+		{"example.com:8080", "example.com", 8080, "example.com:8080"},
+		{"example.com", "example.com", 80, "example.com:80"},
+		{"example.com.", "example.com.", 80, "example.com.:80"},
+		{"10.0.0.1:443", "10.0.0.1", 443, "10.0.0.1:443"},
+		{"[::1]:8080", "::1", 8080, "[::1]:8080"},
+		{"[::1]", "::1", 80, "[::1]:80"},
+		{"::1", "::1", 80, "[::1]:80"},
+		{"[fe80::1%eth0]:22", "fe80::1%eth0", 22, "[fe80::1%eth0]:22"},
+		{":9000", "", 9000, ":9000"},
+		{"localhost:0", "localhost", 0, "localhost:0"},
+	} {
+		opts.Reset()
+		if _, e := opts.Parse([]string{"--listen", c.in}); e != nil {
+			t.Errorf("%s: parse failed: %v", c.in, e)
+			continue
+		}
+		if val.Host != c.host || val.Port != c.port || val.String() != c.str {
+			t.Errorf("%s: got %q %d (%s)", c.in, val.Host, val.Port, val)
+		}
+	}
+	for _, bad := range []string{
+		"", "example.com:", "example.com:http", "example.com:65536",
+		"[::1", "[::1]x", "[10.0.0.1]:80", "::1:80:x", "-bad-.com",
+		"a..b", "under score", "host:-1",
+	} {
+		opts.Reset()
+		mustNotParse(t, opts, []string{"--listen", bad})
+	}
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"--listen", "[::1]:99999"})
+	if e == nil || e.Error() != "failure parsing option value: --listen: "+
+		`invalid port "99999" (must be 0 to 65535)` {
+		t.Errorf("wrong error: %v", e)
+	}
+
+	val.DefaultPort = 0
+	opts.Reset()
+	_, e = opts.Parse([]string{"--listen", "example.com"})
+	if e == nil || e.Error() != "failure parsing option value: --listen: "+
+		`missing port in "example.com"` {
+		t.Errorf("wrong error: %v", e)
+	}
+}
+
+func TestURL(t *testing.T) {
+	opts := &Options{}
+	var u url.URL
+	var plain url.URL
+	w := &URL{Target: &u, Schemes: []string{"http", "https"}}
+	mustAdd(t, opts, &Option{Long: "server", ArgP: w})
+	mustAdd(t, opts, &Option{Long: "any", ArgP: &plain})
+
+	_ = mustParse(t, opts, []string{"--server", "HTTPS://example.com/x?y=1"})
+	if u.Host != "example.com" || u.Path != "/x" || w.String() != "https://example.com/x?y=1" {
+		t.Errorf("wrong URL %v", u.String())
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--any", "ftp://example.com/pub"})
+	if plain.Scheme != "ftp" {
+		t.Errorf("wrong URL %v", plain.String())
+	}
+
+	for _, bad := range []string{"", "example.com", "/relative", "ftp://x", "http://[::1"} {
+		opts.Reset()
+		mustNotParse(t, opts, []string{"--server", bad})
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--any", "relative/path"})
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"--server", "ftp://example.com"})
+	if e == nil || e.Error() != "failure parsing option value: --server: "+
+		`URL scheme "ftp" not permitted (permitted: http, https)` {
+		t.Errorf("wrong error: %v", e)
+	}
+	if u.Host != "example.com" || u.Scheme != "https" {
+		t.Errorf("URL changed by failed parse: %v", u.String())
+	}
+}
+
+func TestNetip(t *testing.T) {
+	opts := &Options{}
+	var addr netip.Addr
+	var ap netip.AddrPort
+	var prefix netip.Prefix
+	e := opts.Add(
+		&Option{Long: "addr", ArgP: &addr},
+		&Option{Long: "addr-port", ArgP: &ap},
+		&Option{Long: "net", ArgP: &prefix},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{
+		"--addr", "2001:db8::1", "--addr-port", "10.1.2.3:53", "--net", "10.0.0.0/8",
+	})
+	if addr != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("wrong addr %v", addr)
+	}
+	if ap != netip.MustParseAddrPort("10.1.2.3:53") {
+		t.Errorf("wrong addr port %v", ap)
+	}
+	if !prefix.Contains(netip.MustParseAddr("10.9.8.7")) || prefix.Bits() != 8 {
+		t.Errorf("wrong prefix %v", prefix)
+	}
+	for _, args := range [][]string{
+		{"--addr", "example.com"},
+		{"--addr", "10.0.0.256"},
+		{"--addr-port", "10.1.2.3"},
+		{"--net", "10.0.0.0/33"},
+		{"--net", "10.0.0.0"},
+	} {
+		opts.Reset()
+		mustNotParse(t, opts, args)
+	}
+}
+
+func TestNet_Help(t *testing.T) {
+	opts := &Options{}
+	var u, plain url.URL
+	var addr netip.Addr
+	var prefix netip.Prefix
+	e := opts.Add(
+		&Option{Long: "listen", ArgP: &HostPort{DefaultPort: 80}, Help: "Listen"},
+		&Option{Long: "peer", ArgP: &HostPort{}, Help: "Peer"},
+		&Option{Long: "server", ArgP: &URL{Target: &u, Schemes: []string{"http", "https"}}, Help: "Server"},
+		&Option{Long: "any", ArgP: &plain, Help: "Any"},
+		&Option{Long: "addr", ArgP: &addr, Help: "Address"},
+		&Option{Long: "allow", ArgP: &prefix, Help: "Allowed"},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --listen HOST[:PORT]    Listen
+  --peer HOST:PORT        Peer
+  --server URL            Server (http or https URL)
+  --any URL               Any
+  --addr ADDR             Address
+  --allow CIDR            Allowed
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
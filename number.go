@@ -0,0 +1,96 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// intText prepares an integer for parsing with the given base,
+// returning the text and base to give to strconv (or math/big).
+func intText(val string, base int) (string, int) {
+	if base != 0 {
+		return val, base
+	}
+	sign := ""
+	digits := val
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X', 'o', 'O', 'b', 'B':
+			return val, 0
+		}
+		// Decimal, so strip leading zeros to avoid treating it as octal.
+		if digits = strings.TrimLeft(digits, "0"); digits == "" {
+			digits = "0"
+		}
+	}
+	return sign + digits, 0
+}
+
+func parseInt(val string, base int, bits int) (int64, error) {
+	text, base := intText(val, base)
+	i, e := strconv.ParseInt(text, base, bits)
+	if errors.Is(e, strconv.ErrRange) {
+		return 0, fmt.Errorf("value out of range [%d, %d]",
+			int64(-1)<<(bits-1), int64(math.MaxInt64>>(64-bits)))
+	}
+	return i, e
+}
+
+func parseUint(val string, base int, bits int) (uint64, error) {
+	text, base := intText(val, base)
+	u, e := strconv.ParseUint(text, base, bits)
+	if errors.Is(e, strconv.ErrRange) ||
+		(e != nil && strings.HasPrefix(text, "-")) {
+		if _, e2 := strconv.ParseInt(text, base, 64); e2 == nil ||
+			errors.Is(e2, strconv.ErrRange) {
+			// Well formed, but negative or too big.
+			return 0, fmt.Errorf("value out of range [0, %d]",
+				uint64(math.MaxUint64>>(64-bits)))
+		}
+	}
+	return u, e
+}
+
+func parseFloat(val string, bits int) (float64, error) {
+	f, e := strconv.ParseFloat(val, bits)
+	if errors.Is(e, strconv.ErrRange) {
+		max := math.MaxFloat64
+		if bits == 32 {
+			max = math.MaxFloat32
+		}
+		return 0, fmt.Errorf("value out of range [%g, %g]", -max, max)
+	}
+	return f, e
+}
+
+func parseBigInt(val string, base int) (*big.Int, error) {
+	text, base := intText(val, base)
+	n, ok := new(big.Int).SetString(text, base)
+	if !ok {
+		return nil, strconv.ErrSyntax
+	}
+	return n, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNumber_Base(t *testing.T) {
+	opts := &Options{}
+	var val int64
+	o := &Option{Long: "n", ArgP: &val}
+	mustAdd(t, opts, o)
+
+	for _, c := range []struct {
+		in   string
+		want int64
+	}{
+		{"10", 10},
+		{"010", 10},
+		{"-007", -7},
+		{"+5", 5},
+		{"0", 0},
+		{"000", 0},
+		{"0x1F", 31},
+		{"-0x10", -16},
+		{"0o17", 15},
+		{"0b101", 5},
+		{"1_000_000", 1000000},
+		{"0xff_ff", 65535},
+	} {
+		opts.Reset()
+		_ = mustParse(t, opts, []string{"--n", c.in})
+		if val != c.want {
+			t.Errorf("%s: got %d, want %d", c.in, val, c.want)
+		}
+	}
+	for _, bad := range []string{"1__0", "_1", "0x", "1.0", "0b2"} {
+		opts.Reset()
+		mustNotParse(t, opts, []string{"--n", bad})
+	}
+
+	o.Base = 10
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--n", "0x10"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--n", "1_000"})
+
+	o.Base = 16
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--n", "ff"})
+	if val != 255 {
+		t.Errorf("wrong hex value %d", val)
+	}
+}
+
+func TestNumber_Range(t *testing.T) {
+	var i8 int8
+	var i16 int16
+	var i32 int32
+	var u uint
+	var u8 uint8
+	var u16 uint16
+	var u32 uint32
+	var f32 float32
+	for _, c := range []struct {
+		p    interface{}
+		good string
+		bad  string
+		msg  string
+	}{
+		{&i8, "-128", "128", "value out of range [-128, 127]"},
+		{&i16, "0x7fff", "-32769", "value out of range [-32768, 32767]"},
+		{&i32, "-2147483648", "2147483648",
+			"value out of range [-2147483648, 2147483647]"},
+		{&u, "0", "-1", ""},
+		{&u8, "255", "256", "value out of range [0, 255]"},
+		{&u8, "0", "-1", "value out of range [0, 255]"},
+		{&u16, "65535", "65536", "value out of range [0, 65535]"},
+		{&u32, "4294967295", "4294967296", "value out of range [0, 4294967295]"},
+		{&f32, "1.5e38", "1e39", "value out of range [-3.4028234663852886e+38, 3.4028234663852886e+38]"},
+	} {
+		opts := &Options{}
+		mustAdd(t, opts, &Option{Long: "n", ArgP: c.p})
+		_ = mustParse(t, opts, []string{"--n", c.good})
+		opts.Reset()
+		_, e := opts.Parse([]string{"--n", c.bad})
+		mustFailAs(t, e, ErrParsingValue)
+		if c.msg != "" && e.Error() != "failure parsing option value: --n: "+c.msg {
+			t.Errorf("wrong message: %v", e)
+		}
+	}
+	if i8 != -128 || i16 != 32767 || u8 != 0 || u16 != 65535 || f32 != 1.5e38 {
+		t.Errorf("values not stored")
+	}
+}
+
+func TestNumber_Types(t *testing.T) {
+	opts := &Options{}
+	var i int
+	var u uint
+	var f float64
+	var n big.Int
+	n.SetInt64(42)
+	keep := &n
+	e := opts.Add(
+		&Option{Short: 'i', ArgP: &i},
+		&Option{Short: 'u', ArgP: &u},
+		&Option{Short: 'f', ArgP: &f},
+		&Option{Short: 'n', ArgP: &n,
+			Validate: func(v interface{}) error {
+				if v.(*big.Int).Sign() < 0 {
+					return ErrParsingValue
+				}
+				return nil
+			}},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"-i", "1000000000", "-u0x10", "-f", "2.5",
+		"-n", "0x1_0000_0000_0000_0000_0000"})
+	if i != 1000000000 || u != 16 || f != 2.5 {
+		t.Errorf("wrong values %d %d %g", i, u, f)
+	}
+	if n.String() != "1208925819614629174706176" || keep != &n {
+		t.Errorf("wrong big value %s", n.String())
+	}
+
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-n", "-5"})
+	if n.String() != "1208925819614629174706176" {
+		t.Errorf("changed big value %s", n.String())
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-n", "junk"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-f", "junk"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-f", "1e400"})
+}
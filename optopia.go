@@ -21,11 +21,12 @@
 package optopia
 
 import (
-	"encoding"
+	"context"
 	"fmt"
-	"strconv"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 type err string
@@ -42,6 +43,52 @@ func mkErr(e error, opt string) err {
 	return err(fmt.Sprintf("%v: %s", e, opt))
 }
 
+// ParseError is the error returned by Options.Parse when an argument
+// cannot be parsed.  Its message begins with the error code, so that
+// the Is method of the error codes works with it, as does errors.Is.
+type ParseError struct {
+	// Code is the error code, for example ErrParsingValue.
+	Code err
+
+	// Arg is the argument that was being parsed, as given.
+	Arg string
+
+	// Option is the option involved, if it is known.
+	Option *Option
+
+	// Err is the underlying cause, if there is one.
+	Err error
+
+	// Source records where the argument came from, if known.  For
+	// errors in the arguments passed to Parse, Source.Index is the
+	// index of the argument at fault, which is the one holding the
+	// value for ErrParsingValue.
+	Source Source
+
+	start, end int // the fault within the argument, see place
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Code.Error()
+	if e.Arg != "" {
+		msg += ": " + e.Arg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is the error code of e.
+func (e *ParseError) Is(target error) bool {
+	return target == e.Code
+}
+
+// Unwrap returns the underlying cause.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 // These are standard error codes.
 const (
 	ErrNoSuchOption        = err("no such option")
@@ -49,6 +96,12 @@ const (
 	ErrParsingValue        = err("failure parsing option value")
 	ErrDuplicateOption     = err("duplicate option")
 	ErrShortAndLongEmpty   = err("long and short options both empty")
+	ErrInvalidOptions      = err("invalid options")
+	ErrBadRelation         = err("invalid option relationship")
+	ErrMissingOption       = err("missing required option")
+	ErrConflictingOptions  = err("conflicting options")
+	ErrRepeatedOption      = err("option repeated")
+	ErrBadOption           = err("invalid option definition")
 )
 
 // Option represents a single option.  Allocate one of these and
@@ -68,36 +121,169 @@ type Option struct {
 	// Used principally in help output.
 	ArgName string
 
-	// ArgP is used to store the value.  At present
-	// this can be a pointer to string, int, int64, uint64, or bool.
-	// It can also be a TextUnmarshaller.
+	// ArgP is used to store the value.  At present this can be a
+	// pointer to string, bool, any of the integer or floating point
+	// types, big.Int, time.Duration or time.Time (see Time).
+	// For names of files or directories, see Path and File.
+	// For network addresses, netip.Addr, netip.AddrPort and
+	// netip.Prefix can be used, as can url.URL (see URL) and HostPort.
+	// For patterns, *regexp.Regexp and Glob can be used.  For several
+	// values in a single argument, see List.  For binary values and
+	// passwords, see Bytes and Secret.
+	// It can also be a TextUnmarshaller, a pointer to a map with
+	// string keys (see Map), or a *Map.  It can also be a pointer
+	// to a slice of any of these, in which case each occurrence
+	// of the option appends to the slice.
+	//
+	// Setting ArgP makes the option take a value, except for a *bool,
+	// which makes it a flag: giving it stores true, and a value can
+	// only be attached, as in --verbose=false or -v=n.  (Set HasArg
+	// to have a *bool take a separate value instead.)
 	ArgP interface{}
 
+	// Base is the base used for integer values.  If it is zero,
+	// values are decimal unless they have a 0x, 0o or 0b prefix, and
+	// may contain underscores between digits, as in Go (but a leading
+	// zero does not mean octal).  Otherwise it is the base, from 2 to
+	// 36, with no prefix or underscores permitted.
+	Base int
+
 	// Handle is executed when this option is found, and passed the
 	// raw string.  If ArgP is set, then any conversion is
 	// is done first.  (If the conversion fails, then that error
 	// is returned to the caller, and Handle is not called.)
+	// Errors from Handle and HandleContext are returned as they are,
+	// unless errors are being collected, so Options.Diagnostic cannot
+	// show which argument they came from.  To have it shown, return
+	// a *ParseError with a Source (see HandlerContext.Index).
 	Handle func(string) error
 
+	// HandleContext is an alternative to Handle, for handlers that
+	// need to know more about the occurrence of the option, or which
+	// need to consume further arguments.  It is called after Handle,
+	// if both are set.
+	HandleContext func(*HandlerContext) error
+
+	// Normalize, if not nil, is called with the raw value before
+	// anything else is done with it.  It returns the value to use
+	// instead, for example with spaces trimmed or lower-cased.
+	Normalize func(string) (string, error)
+
+	// Validate, if not nil, is called with the converted value before
+	// it is stored in ArgP, and before Handle is called.  It is passed
+	// the value that would be stored where ArgP points (for example an
+	// int for an *int), or the string value if ArgP is nil.  For maps
+	// it is passed a map holding just the pairs from this occurrence.
+	Validate func(interface{}) error
+
 	// Help is a short help message about the option.
 	Help string
 
+	// Choices, if not empty, restricts the value to one of the
+	// listed strings.  Any other value is reported as an error
+	// (ErrParsingValue), which lists the valid choices.
+	Choices []string
+
+	// FoldChoices makes matching against Choices case-insensitive.
+	// The value is replaced by the spelling used in Choices before
+	// it is stored or passed to Handle.
+	FoldChoices bool
+
+	// ChoiceHelp optionally describes each of the Choices, keyed by
+	// the choice.  It is used in help output.
+	ChoiceHelp map[string]string
+
+	// Requires lists options that must also be given, if this
+	// option is given.
+	Requires []*Option
+
+	// Implies lists options that are treated as given, if this
+	// option is given.  Implied options cannot take a value, unless
+	// their ArgP is a *bool, which is set to true.
+	Implies []*Option
+
+	// Conflicts lists options that must not be given together
+	// with this option.
+	//
+	// The options in Requires, Implies and Conflicts must be in the
+	// same set, added earlier or in the same call to Options.Add.
+	Conflicts []*Option
+
+	// Repeat determines what happens when the option is given
+	// more than once.
+	Repeat Repeat
+
+	// MinCount is the minimum number of times the option must be
+	// given.  Setting it to 1 makes the option required.
+	MinCount int
+
+	// MaxCount, if not zero, is the maximum number of times the
+	// option may be given.
+	MaxCount int
+
 	// Seen is updated after Options.Parse.  It is true if the option
 	// was seen.  This is useful for options that have no value.
 	Seen bool
 
 	// Raw contains the raw value for options that take one.
-	// It is updated on Options.Parse.
+	// It is updated on Options.Parse.  It is not recorded for
+	// Secret options.
 	Raw string
+
+	// Source records where the value of the option came from.
+	// It is updated on Options.Parse and Options.Set.
+	Source Source
+
+	// Occurrences records each time the option was given, in order.
+	// It is updated on Options.Parse and Options.Set.
+	Occurrences []Occurrence
+
+	// Secret marks the value as sensitive, so that it is redacted
+	// when the configuration is displayed, and left out of errors.
+	// It is set automatically if ArgP is a *Secret.
+	Secret bool
+
+	count   int    // occurrences seen since the last reset
+	layer   int    // occurrences from the current kind of source
+	first   Source // first occurrence from the current kind of source
+	prefix  string // prefix for the long name, from Include
+	section string // title of the help section, from Include
 }
 
 // Options are the main set of Options for a program.  The zero value is
 // usable immediately.
 type Options struct {
+	// BeforeParse, if not nil, is called by Parse before any arguments
+	// are processed.  If it returns an error, Parse returns it.
+	BeforeParse func() error
+
+	// AfterParse, if not nil, is called by Parse after all the options
+	// have been processed successfully, and is passed the residual
+	// arguments.  It can be used, for example, to derive values from
+	// several options.  If it returns an error, Parse returns it.
+	AfterParse func(args []string) error
+
+	// Validate, if not nil, is called after AfterParse, to check the
+	// options as a whole -- for example that --min is not more than
+	// --max.  An error it returns is reported as a ParseError with
+	// code ErrInvalidOptions, unless it is already a *ParseError.
+	Validate func() error
+
+	// CollectErrors makes Parse carry on after errors in the arguments
+	// (see ParseErrors), so that they can all be reported at once.
+	CollectErrors bool
+
+	// Trace, if not nil, is called to report each decision made while
+	// parsing, for debugging.  See TraceLogger and Explain.
+	Trace func(TraceEvent)
+
 	shortOpts map[rune]*Option
 	longOpts  map[string]*Option
 	initOnce  sync.Once
-	allOpts   []*Option // used to preserve order of addition
+	allOpts   []*Option  // used to preserve order of addition
+	included  []*Options // sets added by Include, for their hooks
+
+	occurrences []Occurrence // all occurrences since the last reset
 }
 
 func (o *Options) init() {
@@ -107,175 +293,514 @@ func (o *Options) init() {
 	})
 }
 
-// Add registers a given function.
+// Add registers the given options.  The options are checked first,
+// and if any of them cannot be registered (for example because it
+// duplicates an option already added), none of them are.
 func (o *Options) Add(opts ...*Option) error {
 	o.init()
 	for _, opt := range opts {
-		if opt.ArgP != nil {
-			opt.HasArg = true
+		if o.index(opt) >= 0 {
+			return mkErr(ErrDuplicateOption, opt.name())
 		}
-		if opt.Long == "" && opt.Short == 0 {
-			return ErrShortAndLongEmpty
+	}
+	// The options are prepared before they are checked, so keep copies
+	// to restore if any check fails.
+	saved := make([]Option, len(opts))
+	for i, opt := range opts {
+		saved[i] = *opt
+	}
+	fail := func(e error) error {
+		for i := len(opts) - 1; i >= 0; i-- {
+			*opts[i] = saved[i]
+		}
+		return e
+	}
+	long := map[string]bool{}
+	short := map[rune]bool{}
+	for _, opt := range opts {
+		opt.prefix, opt.section = "", ""
+		opt.prepare()
+		if e := opt.check(); e != nil {
+			return fail(e)
 		}
 		if opt.Long != "" {
-			if o.longOpts[opt.Long] != nil {
-				return mkErr(ErrDuplicateOption, string(opt.Short))
+			if o.longOpts[opt.Long] != nil || long[opt.Long] {
+				return fail(mkErr(ErrDuplicateOption, "--"+opt.Long))
 			}
-			o.longOpts[opt.Long] = opt
+			long[opt.Long] = true
 		}
 		if opt.Short != 0 {
-			if o.shortOpts[opt.Short] != nil {
-				return mkErr(ErrDuplicateOption, string(opt.Short))
+			if o.shortOpts[opt.Short] != nil || short[opt.Short] {
+				return fail(mkErr(ErrDuplicateOption, "-"+string(opt.Short)))
 			}
-			o.shortOpts[opt.Short] = opt
+			short[opt.Short] = true
+		}
+	}
+	all := append(o.All(), opts...)
+	set := optionSet(all)
+	for _, opt := range all {
+		if e := opt.checkRelations(set); e != nil {
+			return fail(e)
+		}
+	}
+	for _, opt := range opts {
+		o.mapNames(opt)
+		opt.reset()
+	}
+	o.allOpts = all
+	return nil
+}
+
+// check checks that the option is well formed, apart from its
+// relations to other options.
+func (opt *Option) check() error {
+	bad := func(format string, args ...interface{}) error {
+		return mkErr(ErrBadOption, opt.name()+": "+fmt.Sprintf(format, args...))
+	}
+	if opt.Long == "" && opt.Short == 0 {
+		return ErrShortAndLongEmpty
+	}
+	if strings.HasPrefix(opt.Long, "-") {
+		return bad("long name must not start with '-'")
+	}
+	if strings.ContainsRune(opt.Long, '=') {
+		return bad("long name must not contain '='")
+	}
+	if strings.IndexFunc(opt.Long, unicode.IsSpace) >= 0 {
+		return bad("long name must not contain spaces")
+	}
+	if opt.Short == '-' || unicode.IsSpace(opt.Short) ||
+		(opt.Short != 0 && !unicode.IsPrint(opt.Short)) {
+		return bad("invalid short name %q", opt.Short)
+	}
+	if opt.Base != 0 && (opt.Base < 2 || opt.Base > 36) {
+		return bad("invalid base %d", opt.Base)
+	}
+	if opt.MinCount < 0 || opt.MaxCount < 0 ||
+		(opt.MaxCount != 0 && opt.MinCount > opt.MaxCount) {
+		return bad("invalid counts (minimum %d, maximum %d)",
+			opt.MinCount, opt.MaxCount)
+	}
+	if opt.ArgP != nil {
+		if e := checkArgP(opt.ArgP); e != nil {
+			return bad("%v", e)
 		}
-		o.allOpts = append(o.allOpts, opt)
-		opt.Seen = false
-		opt.Raw = ""
 	}
 	return nil
 }
 
+// prepare fills in the details implied by ArgP.
+func (opt *Option) prepare() {
+	if _, ok := opt.ArgP.(*bool); ok {
+		// A flag, unless HasArg was set explicitly.
+	} else if opt.ArgP != nil {
+		opt.HasArg = true
+	}
+	if _, ok := opt.ArgP.(*Secret); ok {
+		opt.Secret = true
+	}
+}
+
+// isFlag returns true for boolean options that do not take a
+// separate value.
+func (opt *Option) isFlag() bool {
+	_, ok := opt.ArgP.(*bool)
+	return ok && !opt.HasArg
+}
+
+func (opt *Option) reset() {
+	opt.count = 0
+	opt.layer = 0
+	opt.Seen = false
+	opt.Raw = ""
+	opt.Source = Source{}
+	opt.Occurrences = nil
+	if r, ok := opt.ArgP.(interface{ reset() }); ok {
+		r.reset()
+	}
+}
+
 // Reset resets the values of any Option that has been added.
 // Use it to run through the option parsing multiple times.
+//
+// Values stored where ArgP points are not reset, apart from those of
+// Secret options, which are cleared.  In particular slices and maps
+// that accumulate values keep the values from earlier runs, and new
+// values are added to them.  To start afresh, set the targets to nil
+// (or to their defaults) before parsing again.
 func (o *Options) Reset() {
 	o.init()
-	for _, opt := range o.longOpts {
-		opt.Seen = false
-		opt.Raw = ""
-	}
-	for _, opt := range o.shortOpts {
-		opt.Seen = false
-		opt.Raw = ""
+	for _, opt := range o.allOpts {
+		opt.reset()
 	}
-
+	o.occurrences = nil
 }
 
 // Parse parses the options. Any residual options are returned,
 // and if a parse error that is returned too.
 func (o *Options) Parse(args []string) ([]string, error) {
+	return o.ParseContext(context.Background(), args)
+}
+
+// ParseContext is like Parse, but the given context is made available
+// to any HandleContext functions.
+func (o *Options) ParseContext(ctx context.Context, args []string) ([]string, error) {
 	o.init()
-	for len(args) > 0 {
-		arg := args[0]
-		var opt *Option
+	for _, set := range o.sets() {
+		if set.BeforeParse != nil {
+			if e := set.BeforeParse(); e != nil {
+				return nil, e
+			}
+		}
+	}
+	p := &parser{o: o, ctx: ctx, args: args}
+	args, e := p.run()
+	if e != nil {
+		return nil, e
+	}
+	if e := p.finish(args); e != nil {
+		return nil, e
+	}
+	return args, nil
+}
+
+// run processes the options in the arguments, and returns the
+// residual arguments.
+func (p *parser) run() ([]string, error) {
+	o, args := p.o, p.args
+	ended := false // by an argument
+	for p.pos < len(args) && !p.stop {
+		arg := args[p.pos]
+		end := TraceEvent{Kind: TraceEnd, Value: arg, Index: p.pos}
 		if arg == "--" {
 			// End of options.
-			args = args[1:]
+			o.trace(end, `"--" ends the options`)
+			p.pos++
+			ended = true
 			break
 		}
-		if !strings.HasPrefix(arg, "-") {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			// A lone "-" is an argument, usually meaning stdin.
+			o.trace(end, "%q is not an option, which ends the options", arg)
+			ended = true
 			break
 		}
+		var e error
 		if strings.HasPrefix(arg, "--") {
-			// longOpts form.  First look for an exact match.
-			name := strings.TrimPrefix(arg, "--")
-			if opt = o.longOpts[name]; opt != nil {
-				args = args[1:]
-			} else {
-				// Maybe its a --option=value form.  Try
-				// splitting, but verify that the option
-				// takes an argument.
-				words := strings.SplitN(name, "=", 2)
-				if len(words) == 2 {
-					opt = o.longOpts[words[0]]
-					if opt != nil && opt.HasArg {
-						args[0] = words[1]
-					} else {
-						opt = nil
-					}
-				}
-			}
+			e = p.parseLong(arg)
 		} else {
-			// Starts with "-"
-			name := []rune(arg[1:])
-			opt = o.shortOpts[name[0]]
-			if opt != nil {
-				if len(name) > 1 {
-					if opt.HasArg {
-						// Look for -v= form. This isn't POSIX compliant.
-						// If '=' is short option, then we don't do this.
-						if name[1] == '=' && o.shortOpts['='] == nil {
-							args[0] = string(name[2:])
-						} else {
-							args[0] = string(name[1:])
-						}
-					} else {
-						// Clustered option.
-						args[0] = "-" + string(name[1:])
-					}
-				} else {
-					args = args[1:]
-				}
+			e = p.parseShort(arg)
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+	if p.quit {
+		return nil, nil
+	}
+	if p.stop {
+		o.trace(TraceEvent{Kind: TraceEnd, Index: -1}, "a handler ended the options")
+	} else if !ended {
+		o.trace(TraceEvent{Kind: TraceEnd, Index: -1}, "there are no more arguments")
+	}
+	args = args[p.pos:]
+	if p.rest != "" {
+		// Stopped in the middle of a cluster of short options.
+		args = append([]string{p.rest}, args...)
+	}
+	return args, nil
+}
+
+// finish runs the checks and hooks that follow successful processing
+// of the arguments.  The hooks are not run if errors were collected.
+func (p *parser) finish(args []string) error {
+	o := p.o
+	if e := o.applyRelations(p.ctx, p.fail); e != nil {
+		return e
+	}
+	if e := o.checkCounts(p.fail); e != nil {
+		return e
+	}
+	if len(p.errs) > 0 {
+		return p.errs
+	}
+	for _, set := range o.sets() {
+		if set.AfterParse != nil {
+			if e := set.AfterParse(args); e != nil {
+				return e
 			}
 		}
-		if opt == nil {
-			return nil, mkErr(ErrNoSuchOption, arg)
+	}
+	for _, set := range o.sets() {
+		if set.Validate == nil {
+			continue
+		}
+		if e := set.Validate(); e != nil {
+			if pe, ok := e.(*ParseError); ok {
+				return pe
+			}
+			return &ParseError{Code: ErrInvalidOptions, Err: e}
 		}
+	}
+	return nil
+}
+
+// parser holds the state of a single call to Parse.
+type parser struct {
+	o    *Options
+	ctx  context.Context
+	args []string
+	pos  int         // index of the next argument to process
+	stop bool        // set to end option processing early
+	rest string      // rest of a cluster of short options, when stopping
+	errs ParseErrors // errors collected, see Options.CollectErrors
+
+	// yield, if not nil, is passed the options found, instead of
+	// applying them (see Getopt).  If it returns false, quit is set.
+	yield func(*Option, string) bool
+	quit  bool
+}
 
-		if opt.HasArg && len(args) == 0 {
-			return nil, mkErr(ErrOptionRequiresValue, arg)
+func (p *parser) parseLong(arg string) error {
+	index := p.pos
+	p.pos++
+	// longOpts form.  First look for an exact match.
+	name := strings.TrimPrefix(arg, "--")
+	opt := p.o.longOpts[name]
+	attached := false
+	val := ""
+	if opt == nil {
+		// Maybe its a --option=value form.  Try
+		// splitting, but verify that the option
+		// takes an argument.
+		words := strings.SplitN(name, "=", 2)
+		if len(words) == 2 {
+			opt = p.o.longOpts[words[0]]
+			if opt != nil && (opt.HasArg || opt.isFlag()) {
+				name = words[0]
+				val = words[1]
+				attached = true
+			} else {
+				opt = nil
+			}
+		}
+	}
+	at := place{index: index}
+	if opt == nil {
+		p.o.trace(TraceEvent{Kind: TraceOption, Name: arg, Index: index},
+			"%s is not an option", arg)
+		return p.fail(&ParseError{
+			Code:   ErrNoSuchOption,
+			Arg:    arg,
+			Source: Source{Kind: SourceArgs, Index: index},
+		})
+	}
+	p.o.trace(TraceEvent{Kind: TraceOption, Option: opt, Name: "--" + name, Index: index},
+		"--%s is option %s, %s", name, opt.name(), opt.traceArgs())
+	valAt := at
+	if attached {
+		at.end = len("--" + name)
+		valAt.start, valAt.end = at.end+1, len(arg)
+		p.o.trace(TraceEvent{
+			Kind:   TraceSplit,
+			Option: opt,
+			Name:   "--" + name,
+			Value:  traceValue(opt, val),
+			Index:  index,
+		}, "the value %q is attached to --%s", traceValue(opt, val), name)
+	}
+	if opt.HasArg && !attached {
+		if p.pos >= len(p.args) {
+			return p.fail(&ParseError{
+				Code:   ErrOptionRequiresValue,
+				Arg:    arg,
+				Option: opt,
+				Source: Source{Kind: SourceArgs, Index: index},
+			})
 		}
+		val = p.args[p.pos]
+		valAt = place{index: p.pos}
+		p.traceValue(opt, "--"+name, val)
+		p.pos++
+	}
+	if opt.isFlag() && !attached {
+		val = "true"
+	}
+	return p.apply(opt, arg, "--"+name, val, at, valAt)
+}
 
+func (p *parser) parseShort(arg string) error {
+	index := p.pos
+	p.pos++
+	// Starts with "-"
+	name := []rune(arg[1:])
+	offset := 1 // of the current option within the argument
+	if cluster, ok := p.o.redactCluster(arg); ok {
+		p.o.trace(TraceEvent{Kind: TraceCluster, Name: cluster, Index: index},
+			"%s is a cluster of short options", cluster)
+	}
+	for i := 0; i < len(name) && !p.stop; i++ {
+		at := place{index: index, start: offset}
+		offset += utf8.RuneLen(name[i])
+		at.end = offset
+		// For errors, report the remainder of a cluster,
+		// starting with this option, but not any secret in it.
+		rest, _ := p.o.redactCluster("-" + string(name[i:]))
+		spelling := "-" + string(name[i])
+		opt := p.o.shortOpts[name[i]]
+		if opt == nil {
+			p.o.trace(TraceEvent{Kind: TraceOption, Name: spelling, Index: index},
+				"%s is not an option", spelling)
+			if e := p.fail(&ParseError{
+				Code:   ErrNoSuchOption,
+				Arg:    rest,
+				Source: Source{Kind: SourceArgs, Index: index},
+				start:  at.start,
+				end:    at.end,
+			}); e != nil {
+				return e
+			}
+			continue
+		}
+		p.o.trace(TraceEvent{Kind: TraceOption, Option: opt, Name: spelling, Index: index},
+			"%s is option %s, %s", spelling, opt.name(), opt.traceArgs())
 		val := ""
+		valAt := at
+		// Look for -v= form. This isn't POSIX compliant.
+		// If '=' is short option, then we don't do this.
+		equals := i+1 < len(name) && name[i+1] == '=' && p.o.shortOpts['='] == nil
 		if opt.HasArg {
-			val = args[0]
-			args = args[1:]
+			if i+1 < len(name) {
+				valAt = place{index: index, start: offset, end: len(arg)}
+				if equals {
+					valAt.start++
+				}
+				val = arg[valAt.start:]
+				i = len(name)
+				p.traceSplit(opt, spelling, val, index)
+			} else if p.pos < len(p.args) {
+				val = p.args[p.pos]
+				valAt = place{index: p.pos}
+				p.traceValue(opt, spelling, val)
+				p.pos++
+			} else {
+				return p.fail(&ParseError{
+					Code:   ErrOptionRequiresValue,
+					Arg:    rest,
+					Option: opt,
+					Source: Source{Kind: SourceArgs, Index: index},
+					start:  at.start,
+					end:    at.end,
+				})
+			}
+		} else if opt.isFlag() {
+			val = "true"
+			// A value can only be given in the -v=n form.
+			if equals {
+				valAt = place{index: index, start: offset + 1, end: len(arg)}
+				val = arg[valAt.start:]
+				i = len(name)
+				p.traceSplit(opt, spelling, val, index)
+			}
+		}
+		if e := p.apply(opt, rest, spelling, val, at, valAt); e != nil {
+			return e
 		}
+		if p.stop && i+1 < len(name) {
+			p.rest = "-" + string(name[i+1:])
+		}
+	}
+	return nil
+}
 
-		opt.Seen = true
-		var e error
-		if opt.HasArg {
+// apply applies an option found in the arguments.  The places are
+// those of the option and its value, for reporting errors.
+func (p *parser) apply(opt *Option, arg, spelling, val string, at, valAt place) error {
+	if p.yield != nil {
+		if !p.yield(opt, val) {
+			p.stop, p.quit = true, true
+		}
+		return nil
+	}
+	if opt.Secret {
+		// The argument may include the value.
+		arg = spelling
+	}
+	hc := &HandlerContext{Name: spelling, Index: at.index, ctx: p.ctx, p: p, value: valAt}
+	src := Source{Kind: SourceArgs, Index: at.index}
+	e := p.o.handlerError(p.o.apply(opt, arg, val, src, hc), opt, arg, src)
+	if pe, ok := e.(*ParseError); ok && pe.Option == opt && pe.Source.Kind == SourceArgs {
+		if pe.Code == ErrParsingValue {
+			at = valAt.within(p.args[valAt.index], val, pe.Err)
+		}
+		pe.Source.Index, pe.start, pe.end = at.index, at.start, at.end
+	}
+	return p.fail(e)
+}
+
+// apply applies one occurrence of an option, with the given value.
+// The arg is used for error reporting.  The handler context is passed
+// to HandleContext, after filling in the details of the option.
+func (o *Options) apply(opt *Option, arg string, val string, src Source,
+	hc *HandlerContext) error {
+	o.record(opt, val, src, hc)
+	skip, e := opt.checkRepeat(arg, src)
+	if e != nil {
+		return e
+	}
+	if skip {
+		o.trace(TraceEvent{Kind: TraceIgnore, Option: opt, Name: hc.Name, Index: hc.Index},
+			"%s is ignored, as %s was already given", hc.Name, opt.name())
+		return nil
+	}
+	index := hc.Index // of the value, for tracing
+	if src.Kind == SourceArgs {
+		index = hc.value.index
+	}
+	opt.count++
+	opt.Seen = true
+	opt.Source = src
+	if opt.HasArg || opt.isFlag() {
+		if !opt.Secret {
 			opt.Raw = val
 		}
-		if opt.HasArg && opt.ArgP != nil {
-			switch v := opt.ArgP.(type) {
-			case *bool:
-				// we get 1, 0, true, false variants,
-				// but not yes and no. We want them.
-				switch val {
-				case "y", "Y", "YES", "yes":
-					val = "true"
-				case "n", "N", "NO", "no":
-					val = "false"
-				}
-				*v, e = strconv.ParseBool(val)
-				if e != nil {
-					return nil, mkErr(ErrParsingValue, arg)
-				}
-			case *string:
-				*v = val
-			case *int:
-				i, e := strconv.ParseInt(val, 10, 32)
-				if e != nil {
-					return nil, mkErr(ErrParsingValue, arg)
-				}
-				*v = int(i)
-			case *int64:
-				*v, e = strconv.ParseInt(val, 10, 64)
-				if e != nil {
-					return nil, mkErr(ErrParsingValue, arg)
-				}
-			case *uint64:
-				*v, e = strconv.ParseUint(val, 0, 64)
-				if e != nil {
-					return nil, mkErr(ErrParsingValue, arg)
-				}
-			case encoding.TextUnmarshaler:
-				if e = v.UnmarshalText([]byte(val)); e != nil {
-					return nil, mkErr(ErrParsingValue, arg)
-				}
+		raw := traceValue(opt, val)
+		ev := TraceEvent{Kind: TraceConvert, Option: opt, Value: raw, Index: index}
+		if val, e = opt.store(val); e != nil {
+			ev.Err = e
+			o.trace(ev, "%s: %q could not be stored: %v", opt.name(), raw, e)
+			return &ParseError{
+				Code:   ErrParsingValue,
+				Arg:    arg,
+				Option: opt,
+				Err:    cause(e),
+				Source: src,
 			}
 		}
+		ev.Result = opt.valueString()
+		if opt.Secret && ev.Result != "" {
+			ev.Result = redacted
+		}
+		o.trace(ev, "%s: %q stored as %s", opt.name(), raw, ev.Result)
+	}
 
-		// Handle is only run after doing any type verification.
-		if opt.Handle != nil {
-			if e := opt.Handle(val); e != nil {
-				return nil, e
-			}
-			continue
+	// Handle is only run after doing any type verification.
+	if opt.Handle != nil {
+		e := opt.Handle(val)
+		o.traceHandler(opt, "Handle", val, index, e)
+		if e != nil {
+			return e
 		}
 	}
-	return args, nil
+	if opt.HandleContext != nil {
+		hc.Option = opt
+		hc.Value = val
+		hc.Count = opt.count - 1
+		e := opt.HandleContext(hc)
+		o.traceHandler(opt, "HandleContext", val, index, e)
+		return e
+	}
+	return nil
 }
 
 // Help returns a help string based on the options that have been registered.
@@ -286,7 +811,8 @@ func (o *Options) Help() string {
 		tag  string
 		help string
 	}
-	var lines []line
+	sections := map[string][]line{}
+	titles := []string{""}
 	tagLen := 0
 
 	// calculate length
@@ -296,44 +822,72 @@ func (o *Options) Help() string {
 		if opt.Help == "" {
 			continue
 		}
-		if opt.Short != 0 && opt.Long != "" {
-			_, _ = fmt.Fprintf(tagBuf, "-%c, --%s", opt.Short, opt.Long)
+		long := opt.longName()
+		if opt.Short != 0 && long != "" {
+			_, _ = fmt.Fprintf(tagBuf, "-%c, --%s", opt.Short, long)
 		} else if opt.Short != 0 {
 			_, _ = fmt.Fprintf(tagBuf, "-%c", opt.Short)
-		} else if opt.Long != "" {
-			_, _ = fmt.Fprintf(tagBuf, "--%s", opt.Long)
+		} else if long != "" {
+			_, _ = fmt.Fprintf(tagBuf, "--%s", long)
 		}
+		argName, hint := valueHint(opt.ArgP)
 		if opt.HasArg {
 			if opt.ArgName != "" {
 				_, _ = fmt.Fprintf(tagBuf, " %s", opt.ArgName)
+			} else if argName != "" {
+				_, _ = fmt.Fprintf(tagBuf, " %s", argName)
 			} else {
 				_, _ = fmt.Fprint(tagBuf, " ARG")
 			}
 		}
+		if hint != "" {
+			hint = " (" + hint + ")"
+		}
 		tag := tagBuf.String()
 		if len(tag) > tagLen {
 			tagLen = len(tag)
 		}
+		suffix, extra := opt.choiceHelp()
+		lines, ok := sections[opt.section]
+		if !ok && opt.section != "" {
+			titles = append(titles, opt.section)
+		}
 		lines = append(lines, line{
 			tag:  tag,
-			help: opt.Help,
+			help: opt.Help + hint + suffix + opt.relationHelp(),
 		})
+		for _, help := range extra {
+			lines = append(lines, line{help: help})
+		}
+		sections[opt.section] = lines
 	}
 
-	if len(lines) == 0 {
+	if len(sections) == 0 {
 		return ""
 	}
 
 	tagLen += 4 // Padding
 	result := &strings.Builder{}
-	_, _ = result.WriteString("Options:\n")
-	for _, line := range lines {
-		_, _ = fmt.Fprintf(result, "  %s", line.tag)
-		for i := 0; i < tagLen-len(line.tag); i++ {
-			_ = result.WriteByte(' ')
-		}
-		_, _ = result.WriteString(line.help)
-		_ = result.WriteByte('\n')
+	for _, title := range titles {
+		lines := sections[title]
+		if len(lines) == 0 {
+			continue
+		}
+		if result.Len() > 0 {
+			_ = result.WriteByte('\n')
+		}
+		if title == "" {
+			title = "Options"
+		}
+		_, _ = result.WriteString(title + ":\n")
+		for _, line := range lines {
+			_, _ = fmt.Fprintf(result, "  %s", line.tag)
+			for i := 0; i < tagLen-len(line.tag); i++ {
+				_ = result.WriteByte(' ')
+			}
+			_, _ = result.WriteString(line.help)
+			_ = result.WriteByte('\n')
+		}
 	}
 	return result.String()
 }
@@ -17,10 +17,14 @@
 package optopia
 
 import (
+	"errors"
 	"net"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func mustAdd(t *testing.T, opts *Options, opt *Option) {
@@ -139,6 +143,136 @@ func TestOptions_Add6(t *testing.T) {
 	mustFailAs(t, e, ErrDuplicateOption)
 }
 
+func TestOptions_AddAtomic(t *testing.T) {
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "alpha", Short: 'a'})
+	oB := &Option{Long: "beta"}
+	oC := &Option{Short: 'c'}
+	for _, c := range []struct {
+		opts []*Option
+		msg  string
+	}{
+		{[]*Option{oB, oC, {Long: "alpha"}}, "duplicate option: --alpha"},
+		{[]*Option{oB, oC, {Short: 'a'}}, "duplicate option: -a"},
+		{[]*Option{oB, oC, {Short: 'c'}}, "duplicate option: -c"},
+		{[]*Option{oB, oB}, "duplicate option: --beta"},
+		{[]*Option{oB, oC, {}}, "long and short options both empty"},
+		{[]*Option{oB, oC, {Long: "d e"}},
+			"invalid option definition: --d e: long name must not contain spaces"},
+	} {
+		if e := opts.Add(c.opts...); e == nil || e.Error() != c.msg {
+			t.Errorf("wrong message: %v", e)
+		}
+		if opts.Lookup("beta") != nil || opts.LookupShort('c') != nil ||
+			len(opts.All()) != 1 {
+			t.Errorf("partly added")
+		}
+	}
+
+	// An option already added cannot be added again.
+	mustFailAs(t, opts.Add(opts.Lookup("alpha")), ErrDuplicateOption)
+
+	// A bad relation leaves nothing added.
+	oD := &Option{Long: "delta", HasArg: true}
+	mustFailAs(t, opts.Add(oD, &Option{Long: "echo", Implies: []*Option{oD}}), ErrBadRelation)
+	if opts.Lookup("delta") != nil || len(opts.All()) != 1 {
+		t.Errorf("partly added")
+	}
+	if e := opts.Add(oB, oC); e != nil {
+		t.Errorf("add failed: %v", e)
+	}
+
+	// Nor does adding an included option change it.
+	var cert string
+	sub := &Options{}
+	oCert := &Option{Long: "cert", ArgP: &cert}
+	mustAdd(t, sub, oCert)
+	if e := opts.Include(sub, "tls-", "TLS"); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	mustFailAs(t, opts.Add(oCert), ErrDuplicateOption)
+	mustFailAs(t, opts.Add(&Option{Long: "foxtrot"}, oCert), ErrDuplicateOption)
+	if oCert.name() != "--tls-cert" || opts.Lookup("foxtrot") != nil {
+		t.Errorf("option changed: %s", oCert.name())
+	}
+	_ = mustParse(t, opts, []string{"--tls-cert", "c.pem"})
+	if cert != "c.pem" {
+		t.Errorf("wrong value %q", cert)
+	}
+}
+
+type notSettable struct{}
+
+func TestOptions_AddChecks(t *testing.T) {
+	var i int
+	var ip *int
+	var s notSettable
+	var ch chan int
+	var m map[int]string
+	var sm map[string]notSettable
+	var ss []notSettable
+	var tm time.Time
+	for _, c := range []struct {
+		opt *Option
+		msg string
+	}{
+		{&Option{Long: "a=b"}, "--a=b: long name must not contain '='"},
+		{&Option{Long: "a\tb"}, "--a\tb: long name must not contain spaces"},
+		{&Option{Long: "-a"}, "---a: long name must not start with '-'"},
+		{&Option{Short: '-'}, "--: invalid short name '-'"},
+		{&Option{Short: ' '}, "- : invalid short name ' '"},
+		{&Option{Long: "x", Short: '\x00' + 1}, "--x: invalid short name '\\x01'"},
+		{&Option{Long: "x", ArgP: &i, Base: 1}, "--x: invalid base 1"},
+		{&Option{Long: "x", ArgP: &i, Base: 37}, "--x: invalid base 37"},
+		{&Option{Long: "x", MinCount: 2, MaxCount: 1}, "--x: invalid counts (minimum 2, maximum 1)"},
+		{&Option{Long: "x", MinCount: -1}, "--x: invalid counts (minimum -1, maximum 0)"},
+		{&Option{Long: "x", ArgP: i}, "--x: cannot store values in int"},
+		{&Option{Long: "x", ArgP: ip}, "--x: ArgP is a nil *int"},
+		{&Option{Long: "x", ArgP: &ip}, "--x: cannot store values in **int"},
+		{&Option{Long: "x", ArgP: &s}, "--x: cannot store values in *optopia.notSettable"},
+		{&Option{Long: "x", ArgP: &ch}, "--x: cannot store values in *chan int"},
+		{&Option{Long: "x", ArgP: &m}, "--x: cannot store values in *map[int]string"},
+		{&Option{Long: "x", ArgP: &sm}, "--x: cannot store values of type optopia.notSettable"},
+		{&Option{Long: "x", ArgP: &ss}, "--x: cannot store values of type optopia.notSettable"},
+		{&Option{Long: "x", ArgP: &Map{}}, "--x: map target <nil> is not a pointer to a map with string keys"},
+		{&Option{Long: "x", ArgP: &List{Target: &i}}, "--x: list target *int is not a pointer to a slice"},
+		{&Option{Long: "x", ArgP: &Time{}}, "--x: *optopia.Time has a nil Target"},
+		{&Option{Long: "x", ArgP: &Path{}}, "--x: *optopia.Path has a nil Target"},
+		{&Option{Long: "x", ArgP: (*Time)(nil)}, "--x: ArgP is a nil *optopia.Time"},
+	} {
+		opts := &Options{}
+		e := opts.Add(c.opt)
+		mustFailAs(t, e, ErrBadOption)
+		if e != nil && e.Error() != "invalid option definition: "+c.msg {
+			t.Errorf("wrong message: %v", e)
+		}
+	}
+
+	// These are all fine.
+	var b []byte
+	var ms map[string]time.Duration
+	var ls []*regexp.Regexp
+	var ip2 net.IP
+	opts := &Options{}
+	e := opts.Add(
+		&Option{Long: "a", ArgP: &b},
+		&Option{Long: "b", ArgP: &ms},
+		&Option{Long: "c", ArgP: &ls},
+		&Option{Long: "d", ArgP: &ip2},
+		&Option{Long: "e", ArgP: &Time{Target: &tm}},
+		&Option{Long: "f", ArgP: &File{}},
+		&Option{Long: "g", ArgP: &Secret{}},
+		&Option{Long: "h", ArgP: &List{Target: &ls}},
+		&Option{Long: "i", ArgP: &i, Base: 36, MinCount: 1, MaxCount: 1},
+		&Option{Long: "j", ArgP: &i, MinCount: 2},
+		&Option{Short: '='},
+		&Option{Long: "пипец", Short: 'Г'},
+	)
+	if e != nil {
+		t.Errorf("add failed: %v", e)
+	}
+}
+
 func TestOptions_Reset(t *testing.T) {
 	opts := &Options{}
 	o := &Option{
@@ -663,7 +797,7 @@ func TestOptions_Parse17(t *testing.T) {
 	}
 	mustAdd(t, opts, oX)
 
-	args, err := opts.Parse([]string{"--y", "true", "--", "--wrong", "extra"})
+	args, err := opts.Parse([]string{"--y=true", "--", "--wrong", "extra"})
 	if err != nil {
 		t.Fail()
 	}
@@ -690,7 +824,7 @@ func TestOptions_Parse18(t *testing.T) {
 	}
 	mustAdd(t, opts, oX)
 
-	args := mustParse(t, opts, []string{"--y", "yes", "--", "--wrong", "extra"})
+	args := mustParse(t, opts, []string{"--y=yes", "--", "--wrong", "extra"})
 	if len(args) != 2 || args[0] != "--wrong" || args[1] != "extra" {
 		t.Fatal("oops")
 	}
@@ -705,31 +839,31 @@ func TestOptions_Parse18(t *testing.T) {
 	}
 
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "YES"})
+	_ = mustParse(t, opts, []string{"--y=YES"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "Y"})
+	_ = mustParse(t, opts, []string{"--y=Y"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "y"})
+	_ = mustParse(t, opts, []string{"--y=y"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "NO"})
+	_ = mustParse(t, opts, []string{"--y=NO"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "no"})
+	_ = mustParse(t, opts, []string{"--y=no"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "N"})
+	_ = mustParse(t, opts, []string{"--y=N"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "n"})
+	_ = mustParse(t, opts, []string{"--y=n"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "1"})
+	_ = mustParse(t, opts, []string{"--y=1"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "t"})
+	_ = mustParse(t, opts, []string{"--y=t"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "0"})
+	_ = mustParse(t, opts, []string{"--y=0"})
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--y", "f"})
+	_ = mustParse(t, opts, []string{"--y=f"})
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--y", "2"})
+	mustNotParse(t, opts, []string{"--y=2"})
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--y", "bogus"})
+	mustNotParse(t, opts, []string{"--y=bogus"})
 
 }
 
@@ -768,9 +902,12 @@ func TestOptions_Parse19(t *testing.T) {
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--x", ""})
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--x", "0x100"})
+	_ = mustParse(t, opts, []string{"--x", "0x100"})
+	if val != 256 {
+		t.FailNow()
+	}
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--x", "1000000000000"})
+	mustNotParse(t, opts, []string{"--x", "100000000000000000000"})
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--x", "Junk"})
 
@@ -816,7 +953,10 @@ func TestOptions_Parse20(t *testing.T) {
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--x", ""})
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--x", "0x100"})
+	_ = mustParse(t, opts, []string{"--x", "0x100"})
+	if val != 256 {
+		t.FailNow()
+	}
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--x", "JUNK"})
 
@@ -891,11 +1031,10 @@ func TestOptions_Parse22(t *testing.T) {
 		t.Error("did not store in receiver")
 	}
 
+	// Since Go 1.17, leading zeros are rejected, as some parsers read
+	// them as octal.
 	opts.Reset()
-	_ = mustParse(t, opts, []string{"--ip", "001.002.3.04"})
-	if val.String() != "1.2.3.4" {
-		t.FailNow()
-	}
+	mustNotParse(t, opts, []string{"--ip", "001.002.3.04"})
 	opts.Reset()
 	_ = mustParse(t, opts, []string{"--ip", "127.0.0.1"})
 	if val.IsMulticast() || !val.IsLoopback() {
@@ -961,7 +1100,7 @@ func TestOptions_Parse23(t *testing.T) {
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--i", "localhost"})
 	opts.Reset()
-	mustNotParse(t, opts, []string{"--i", "0x123"})
+	mustNotParse(t, opts, []string{"--i", "0x12g"})
 	opts.Reset()
 	mustNotParse(t, opts, []string{"--i", "JUNK"})
 
@@ -1036,3 +1175,255 @@ func TestOptions_Help2(t *testing.T) {
 		t.Fatalf("not empty string")
 	}
 }
+
+func TestOptions_Validate(t *testing.T) {
+	opts := &Options{}
+	var port int
+	handled := false
+	o := &Option{
+		Long: "port",
+		ArgP: &port,
+		Validate: func(v interface{}) error {
+			if p := v.(int); p < 1 || p > 65535 {
+				return errors.New("must be between 1 and 65535")
+			}
+			return nil
+		},
+		Handle: func(string) error {
+			handled = true
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"--port", "8080"})
+	if port != 8080 || !handled {
+		t.Errorf("did not store value")
+	}
+
+	opts.Reset()
+	handled = false
+	_, e := opts.Parse([]string{"--port", "70000"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != "failure parsing option value: --port: "+
+		"must be between 1 and 65535" {
+		t.Errorf("wrong message: %v", e)
+	}
+	if port != 8080 || handled {
+		t.Errorf("stored value that failed validation")
+	}
+	var pe *ParseError
+	if !errors.As(e, &pe) || pe.Option != o || pe.Arg != "--port" {
+		t.Errorf("wrong error details: %#v", e)
+	}
+	if !errors.Is(e, ErrParsingValue) || errors.Is(e, ErrNoSuchOption) {
+		t.Errorf("wrong error code")
+	}
+}
+
+func TestOptions_Normalize(t *testing.T) {
+	opts := &Options{}
+	var val string
+	var seen interface{}
+	o := &Option{
+		Long:    "mode",
+		ArgP:    &val,
+		Choices: []string{"fast", "slow"},
+		Normalize: func(s string) (string, error) {
+			if s == "" {
+				return "", errors.New("empty mode")
+			}
+			return strings.ToLower(strings.TrimSpace(s)), nil
+		},
+		Validate: func(v interface{}) error {
+			seen = v
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"--mode", " FAST "})
+	if val != "fast" || seen != "fast" || o.Raw != " FAST " {
+		t.Errorf("did not normalize value: %q", val)
+	}
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"--mode="})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != "failure parsing option value: --mode=: empty mode" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	// Validate gets the string if there is no ArgP.
+	o.ArgP = nil
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--mode", "Slow"})
+	if seen != "slow" {
+		t.Errorf("validate not called with string")
+	}
+}
+
+func TestOptions_Hooks(t *testing.T) {
+	var calls []string
+	var min, max int
+	opts := &Options{
+		BeforeParse: func() error {
+			calls = append(calls, "before")
+			return nil
+		},
+		AfterParse: func(args []string) error {
+			calls = append(calls, "after "+strings.Join(args, " "))
+			return nil
+		},
+		Validate: func() error {
+			calls = append(calls, "validate")
+			if min > max {
+				return errors.New("--min must not exceed --max")
+			}
+			return nil
+		},
+	}
+	mustAdd(t, opts, &Option{Long: "min", ArgP: &min})
+	mustAdd(t, opts, &Option{Long: "max", ArgP: &max})
+
+	_ = mustParse(t, opts, []string{"--min", "1", "--max", "2", "x"})
+	if got := strings.Join(calls, ","); got != "before,after x,validate" {
+		t.Errorf("wrong calls: %s", got)
+	}
+
+	opts.Reset()
+	calls = nil
+	args, e := opts.Parse([]string{"--min", "3", "--max", "2"})
+	mustFailAs(t, e, ErrInvalidOptions)
+	if args != nil || e.Error() != "invalid options: --min must not exceed --max" {
+		t.Errorf("wrong result: %v", e)
+	}
+	if !errors.Is(e, ErrInvalidOptions) {
+		t.Errorf("wrong code")
+	}
+
+	// Hooks are not run after failures.
+	opts.Reset()
+	calls = nil
+	_, e = opts.Parse([]string{"--bogus"})
+	mustFailAs(t, e, ErrNoSuchOption)
+	if got := strings.Join(calls, ","); got != "before" {
+		t.Errorf("wrong calls: %s", got)
+	}
+
+	// A ParseError from Validate is returned as is.
+	opts.Validate = func() error {
+		return &ParseError{Code: ErrParsingValue, Arg: "--max"}
+	}
+	opts.Reset()
+	_, e = opts.Parse(nil)
+	mustFailAs(t, e, ErrParsingValue)
+}
+
+func TestOptions_HookErrors(t *testing.T) {
+	opts := &Options{
+		BeforeParse: func() error { return errors.New("before") },
+	}
+	if _, e := opts.Parse(nil); e == nil || e.Error() != "before" {
+		t.Errorf("before hook error not returned")
+	}
+	opts = &Options{
+		AfterParse: func([]string) error { return errors.New("after") },
+	}
+	if _, e := opts.Parse(nil); e == nil || e.Error() != "after" {
+		t.Errorf("after hook error not returned")
+	}
+}
+
+func TestOptions_ParseDash(t *testing.T) {
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Short: 'v'})
+	args := mustParse(t, opts, []string{"-"})
+	if len(args) != 1 || args[0] != "-" {
+		t.Errorf("lone - not returned: %v", args)
+	}
+	args = mustParse(t, opts, []string{"-v", "-", "-v"})
+	if len(args) != 2 || args[0] != "-" {
+		t.Errorf("lone - not returned: %v", args)
+	}
+}
+
+func TestOptions_Flags(t *testing.T) {
+	opts := &Options{}
+	var verbose, force, old bool
+	oV := &Option{Long: "verbose", Short: 'v', ArgP: &verbose, Help: "Verbose"}
+	oF := &Option{Short: 'f', ArgP: &force, Help: "Force"}
+	oO := &Option{Long: "old", ArgP: &old, HasArg: true, Help: "Old style"}
+	mustAdd(t, opts, oV)
+	mustAdd(t, opts, oF)
+	mustAdd(t, opts, oO)
+	if oV.HasArg || oF.HasArg || !oO.HasArg {
+		t.Errorf("wrong HasArg")
+	}
+
+	args := mustParse(t, opts, []string{"--verbose", "false"})
+	if !verbose || len(args) != 1 || args[0] != "false" || oV.Raw != "true" {
+		t.Errorf("flag took a value: %v %v", verbose, args)
+	}
+	verbose = false
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-vf"})
+	if !verbose || !force {
+		t.Errorf("cluster not handled")
+	}
+
+	for _, c := range []struct {
+		args    []string
+		verbose bool
+		force   bool
+	}{
+		{[]string{"--verbose=false"}, false, false},
+		{[]string{"--verbose=yes"}, true, false},
+		{[]string{"-v=n", "-f"}, false, true},
+		{[]string{"-fv=N"}, false, true},
+		{[]string{"-f=no", "-v"}, true, false},
+		{[]string{"-v", "--verbose=0"}, false, false},
+	} {
+		verbose, force = true, false
+		opts.Reset()
+		_ = mustParse(t, opts, c.args)
+		if verbose != c.verbose || force != c.force {
+			t.Errorf("%v: got %v %v", c.args, verbose, force)
+		}
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--verbose=maybe"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-v="})
+	opts.Reset()
+	_, e := opts.Parse([]string{"-vx"})
+	mustFailAs(t, e, ErrNoSuchOption)
+
+	// Explicit HasArg keeps the old behavior.
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--old", "yes"})
+	if !old {
+		t.Errorf("old style not set")
+	}
+	opts.Reset()
+	_, e = opts.Parse([]string{"--old"})
+	mustFailAs(t, e, ErrOptionRequiresValue)
+
+	verbose = false
+	opts.Reset()
+	if e = opts.Set("verbose", "", Source{Kind: SourceEnv, Name: "VERBOSE"}); e != nil || !verbose {
+		t.Errorf("set failed: %v", e)
+	}
+	if e = opts.Set("v", "off", Source{Kind: SourceEnv, Name: "VERBOSE"}); e == nil {
+		t.Errorf("set of bad value passed")
+	}
+
+	good := `Options:
+  -v, --verbose    Verbose
+  -f               Force
+  --old ARG        Old style
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Path is used as an ArgP for options that name a file or directory,
+// and can check that it exists.
+type Path struct {
+	// Target is where the path is stored.
+	Target *string
+
+	// MustExist requires the path to exist.
+	MustExist bool
+
+	// File requires the path to exist, and not be a directory.
+	File bool
+
+	// Dir requires the path to exist, and be a directory.
+	Dir bool
+
+	// Readable requires the path to exist, and be readable.
+	Readable bool
+}
+
+func (p *Path) check(name string) error {
+	if name == "" {
+		return errors.New("empty path")
+	}
+	if !p.MustExist && !p.File && !p.Dir && !p.Readable {
+		return nil
+	}
+	info, e := os.Stat(name)
+	if e != nil {
+		return pathErr(name, e)
+	}
+	if p.File && info.IsDir() {
+		return fmt.Errorf("%q is a directory", name)
+	}
+	if p.Dir && !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", name)
+	}
+	if p.Readable {
+		f, e := os.Open(name)
+		if e != nil {
+			return pathErr(name, e)
+		}
+		_ = f.Close()
+	}
+	return nil
+}
+
+func pathErr(name string, e error) error {
+	switch {
+	case os.IsNotExist(e):
+		return fmt.Errorf("%q does not exist", name)
+	case os.IsPermission(e):
+		return fmt.Errorf("%q is not accessible", name)
+	}
+	return e
+}
+
+func (p *Path) convert(val string, _ *Option) (interface{}, func(), error) {
+	if e := p.check(val); e != nil {
+		return nil, nil, e
+	}
+	return val, func() { *p.Target = val }, nil
+}
+
+func (p *Path) hint() (string, string) {
+	switch {
+	case p.Dir:
+		return "DIR", ""
+	case p.File:
+		return "FILE", ""
+	}
+	return "PATH", ""
+}
+
+func (p *Path) String() string {
+	if p.Target == nil {
+		return ""
+	}
+	return *p.Target
+}
+
+// File is a file named by an option, which is opened when needed.
+// A *File can be used as ArgP.  The name "-" refers to standard input
+// (or standard output, for files being written).  When parsing a file
+// that is to be read, it is an error if the file does not exist.
+type File struct {
+	// Name is the name of the file.
+	Name string
+
+	// Write indicates that the file is to be written, rather than read.
+	// The file is created if necessary.
+	Write bool
+
+	// Append, for files being written, appends to an existing file
+	// rather than truncating it.
+	Append bool
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *File) UnmarshalText(text []byte) error {
+	name := string(text)
+	if name == "" {
+		return errors.New("empty file name")
+	}
+	if !f.Write && name != "-" {
+		info, e := os.Stat(name)
+		if e != nil {
+			return pathErr(name, e)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%q is a directory", name)
+		}
+	}
+	f.Name = name
+	return nil
+}
+
+// IsStdio returns true if the file refers to standard input or output.
+func (f *File) IsStdio() bool {
+	return f.Name == "-"
+}
+
+// Reader opens the file for reading.  The caller should close it,
+// which for standard input does nothing.
+func (f *File) Reader() (io.ReadCloser, error) {
+	if f.IsStdio() {
+		return stdio{os.Stdin}, nil
+	}
+	return os.Open(f.Name)
+}
+
+// Writer opens the file for writing.  The caller should close it,
+// which for standard output does nothing.
+func (f *File) Writer() (io.WriteCloser, error) {
+	if f.IsStdio() {
+		return stdio{os.Stdout}, nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if f.Append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(f.Name, flags, 0666)
+}
+
+func (f *File) String() string {
+	return f.Name
+}
+
+func (f *File) hint() (string, string) {
+	if f.Write {
+		return "FILE", `"-" for standard output`
+	}
+	return "FILE", `"-" for standard input`
+}
+
+// stdio wraps standard input or output, so that closing it
+// does not close the underlying file.
+type stdio struct {
+	*os.File
+}
+
+func (stdio) Close() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustTempDir(t *testing.T) string {
+	dir := t.TempDir()
+	if e := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); e != nil {
+		t.Fatalf("cannot make file: %v", e)
+	}
+	return dir
+}
+
+func TestPath(t *testing.T) {
+	dir := mustTempDir(t)
+	file := filepath.Join(dir, "file")
+	missing := filepath.Join(dir, "missing")
+
+	var val string
+	p := &Path{Target: &val}
+	opts := &Options{}
+	mustAdd(t, opts, &Option{Long: "path", ArgP: p})
+
+	_ = mustParse(t, opts, []string{"--path", missing})
+	if val != missing {
+		t.Errorf("wrong path %q", val)
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--path", ""})
+
+	p.MustExist = true
+	opts.Reset()
+	_, e := opts.Parse([]string{"--path", missing})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != "failure parsing option value: --path: "+
+		`"`+missing+`" does not exist` {
+		t.Errorf("wrong message: %v", e)
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--path", dir})
+
+	p.MustExist = false
+	p.File = true
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--path", dir})
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--path", file})
+
+	p.File = false
+	p.Dir = true
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--path", file})
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--path", dir})
+
+	p.Dir = false
+	p.Readable = true
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--path", file})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--path", missing})
+	if formatValue(p) != file {
+		t.Errorf("wrong format")
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := mustTempDir(t)
+	in := &File{}
+	out := &File{Write: true}
+	opts := &Options{}
+	e := opts.Add(
+		&Option{Short: 'i', ArgP: in},
+		&Option{Short: 'o', ArgP: out},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	args := mustParse(t, opts, []string{"-i", "-", "-o-", "-"})
+	if len(args) != 1 || args[0] != "-" {
+		t.Errorf("lone - not an argument: %v", args)
+	}
+	if !in.IsStdio() || !out.IsStdio() || out.Write != true {
+		t.Errorf("not stdio")
+	}
+	r, e := in.Reader()
+	if e != nil || r.(stdio).File != os.Stdin || r.Close() != nil {
+		t.Errorf("not stdin")
+	}
+	w, e := out.Writer()
+	if e != nil || w.(stdio).File != os.Stdout || w.Close() != nil {
+		t.Errorf("not stdout")
+	}
+
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-i", filepath.Join(dir, "missing")})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-i", dir})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-o", ""})
+
+	name := filepath.Join(dir, "out")
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-i", filepath.Join(dir, "file"), "-o", name})
+	w, e = out.Writer()
+	if e != nil {
+		t.Fatalf("cannot write: %v", e)
+	}
+	_, _ = w.Write([]byte("hello"))
+	_ = w.Close()
+	out.Append = true
+	w, _ = out.Writer()
+	_, _ = w.Write([]byte(" there"))
+	_ = w.Close()
+
+	in.Name = name
+	r, e = in.Reader()
+	if e != nil {
+		t.Fatalf("cannot read: %v", e)
+	}
+	b, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(b) != "hello there" {
+		t.Errorf("wrong content %q", b)
+	}
+}
+
+func TestPath_Help(t *testing.T) {
+	opts := &Options{}
+	var a, b, c string
+	e := opts.Add(
+		&Option{Long: "in", ArgP: &File{}, Help: "Input"},
+		&Option{Long: "out", ArgP: &File{Write: true}, Help: "Output"},
+		&Option{Long: "dir", ArgP: &Path{Target: &a, Dir: true}, Help: "Dir"},
+		&Option{Long: "file", ArgP: &Path{Target: &b, File: true}, Help: "File"},
+		&Option{Long: "path", ArgP: &Path{Target: &c}, Help: "Path"},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --in FILE      Input ("-" for standard input)
+  --out FILE     Output ("-" for standard output)
+  --dir DIR      Dir
+  --file FILE    File
+  --path PATH    Path
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
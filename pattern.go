@@ -0,0 +1,182 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// posError is an error at a particular place within a value.
+type posError struct {
+	msg string
+	val string
+	pos int // byte offset into val
+}
+
+func (e *posError) Error() string {
+	pos := e.pos
+	if pos > len(e.val) {
+		pos = len(e.val)
+	}
+	return fmt.Sprintf("%s at position %d",
+		e.msg, utf8.RuneCountInString(e.val[:pos])+1)
+}
+
+// compileRegexp compiles a regular expression, reporting where in the
+// pattern any problem was found.  A **regexp.Regexp can be used as ArgP.
+func compileRegexp(val string) (*regexp.Regexp, error) {
+	re, e := regexp.Compile(val)
+	var se *syntax.Error
+	if e == nil || !errors.As(e, &se) {
+		return re, e
+	}
+	pos := len(val)
+	switch {
+	case se.Code == syntax.ErrMissingParen, se.Expr == "":
+		// The problem is at the end, where a ')' was expected.
+	case se.Code == syntax.ErrUnexpectedParen:
+		pos = unmatchedParen(val)
+	case strings.Contains(val, se.Expr):
+		pos = strings.Index(val, se.Expr)
+	}
+	return nil, &posError{
+		msg: fmt.Sprintf("invalid regexp %q: %s", val, se.Code),
+		val: val,
+		pos: pos,
+	}
+}
+
+// unmatchedParen finds the first ')' that closes nothing, skipping
+// escaped characters and character classes.
+func unmatchedParen(val string) int {
+	depth := 0
+	class := false
+	for i := 0; i < len(val); i++ {
+		switch c := val[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			if strings.HasPrefix(val[i+1:], "]") {
+				i++
+			} else if strings.HasPrefix(val[i+1:], "^]") {
+				i += 2
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(val)
+}
+
+// Glob is a shell pattern, as for path.Match, where * matches any run of
+// characters other than '/', ? matches one such character, [a-z] matches
+// a character class, and \ quotes the following character.  A *Glob can
+// be used as ArgP; patterns with syntax errors are rejected.
+type Glob string
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (g *Glob) UnmarshalText(text []byte) error {
+	val := string(text)
+	if e := checkGlob(val); e != nil {
+		return e
+	}
+	*g = Glob(val)
+	return nil
+}
+
+// Match reports whether the name matches the pattern.
+func (g Glob) Match(name string) bool {
+	ok, _ := path.Match(string(g), name)
+	return ok
+}
+
+func (g *Glob) hint() (string, string) {
+	return "GLOB", ""
+}
+
+// checkGlob checks the syntax of a pattern, following the grammar
+// documented for path.Match.
+func checkGlob(val string) error {
+	bad := func(msg string, pos int) error {
+		return &posError{
+			msg: fmt.Sprintf("invalid pattern %q: %s", val, msg),
+			val: val,
+			pos: pos,
+		}
+	}
+	// globChar checks a character in a class, returning the offset
+	// of whatever follows it.
+	globChar := func(i int) (int, bool) {
+		if i >= len(val) || val[i] == '-' || val[i] == ']' {
+			return i, false
+		}
+		if val[i] == '\\' {
+			if i++; i >= len(val) {
+				return i, false
+			}
+		}
+		_, n := utf8.DecodeRuneInString(val[i:])
+		return i + n, true
+	}
+	for i := 0; i < len(val); i++ {
+		switch val[i] {
+		case '\\':
+			if i+1 == len(val) {
+				return bad("trailing backslash", i)
+			}
+			i++
+		case '[':
+			start := i
+			if i++; i < len(val) && val[i] == '^' {
+				i++
+			}
+			for n := 0; i >= len(val) || val[i] != ']' || n == 0; n++ {
+				if i >= len(val) {
+					return bad("missing closing ]", start)
+				}
+				var ok bool
+				if i, ok = globChar(i); !ok {
+					return bad("invalid character class", i)
+				}
+				if i < len(val) && val[i] == '-' {
+					if i, ok = globChar(i + 1); !ok {
+						return bad("invalid character class range", i)
+					}
+				}
+			}
+		}
+	}
+	if _, e := path.Match(val, ""); e != nil {
+		// Our checks should agree with path.Match, but just in case.
+		return bad(e.Error(), 0)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	opts := &Options{}
+	var re *regexp.Regexp
+	var res []*regexp.Regexp
+	e := opts.Add(
+		&Option{Long: "match", ArgP: &re},
+		&Option{Long: "exclude", ArgP: &res},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{
+		"--match", "^foo", "--exclude", `\.o$`, "--exclude", "~$",
+	})
+	if re == nil || !re.MatchString("foobar") || re.MatchString("barfoo") {
+		t.Errorf("wrong regexp %v", re)
+	}
+	if len(res) != 2 || !res[0].MatchString("x.o") || !res[1].MatchString("x~") {
+		t.Errorf("wrong regexps %v", res)
+	}
+
+	for _, c := range []struct {
+		in  string
+		msg string
+	}{
+		{"a(b", `invalid regexp "a(b": missing closing ) at position 4`},
+		{"a)b", `invalid regexp "a)b": unexpected ) at position 2`},
+		{`[)]\))`, `invalid regexp "[)]\\))": unexpected ) at position 6`},
+		{"ab**", `invalid regexp "ab**": invalid nested repetition operator at position 3`},
+		{"x[a-", `invalid regexp "x[a-": missing closing ] at position 2`},
+		{"é{2,1}", `invalid regexp "é{2,1}": invalid repeat count at position 2`},
+		{`ab\`, `invalid regexp "ab\\": trailing backslash at end of expression at position 4`},
+	} {
+		opts.Reset()
+		_, e := opts.Parse([]string{"--match", c.in})
+		mustFailAs(t, e, ErrParsingValue)
+		if e != nil && e.Error() != "failure parsing option value: --match: "+c.msg {
+			t.Errorf("%s: wrong message: %v", c.in, e)
+		}
+	}
+	if re == nil || re.String() != "^foo" {
+		t.Errorf("regexp changed by failed parse: %v", re)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	opts := &Options{}
+	var g Glob
+	var gs []Glob
+	e := opts.Add(
+		&Option{Long: "include", ArgP: &g},
+		&Option{Long: "skip", ArgP: &gs},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"--include", "*.go", "--skip", `[!a-z]?`, "--skip", `\*`})
+	if g != "*.go" || !g.Match("main.go") || g.Match("dir/main.go") {
+		t.Errorf("wrong glob %q", g)
+	}
+	if len(gs) != 2 || !gs[0].Match("!x") || !gs[1].Match("*") || gs[1].Match("x") {
+		t.Errorf("wrong globs %q", gs)
+	}
+
+	for _, good := range []string{"", "x]", "[^a]", `[\]]`, "[a-c]", "[é-ü]*"} {
+		opts.Reset()
+		_ = mustParse(t, opts, []string{"--include", good})
+	}
+	for _, c := range []struct {
+		in  string
+		msg string
+	}{
+		{`ab\`, `invalid pattern "ab\\": trailing backslash at position 3`},
+		{"*.[ch", `invalid pattern "*.[ch": missing closing ] at position 3`},
+		{"[]a]", `invalid pattern "[]a]": invalid character class at position 2`},
+		{"[^]", `invalid pattern "[^]": invalid character class at position 3`},
+		{"[-a]", `invalid pattern "[-a]": invalid character class at position 2`},
+		{"ü[a-]", `invalid pattern "ü[a-]": invalid character class range at position 5`},
+	} {
+		opts.Reset()
+		_, e := opts.Parse([]string{"--include", c.in})
+		mustFailAs(t, e, ErrParsingValue)
+		if e != nil && e.Error() != "failure parsing option value: --include: "+c.msg {
+			t.Errorf("%s: wrong message: %v", c.in, e)
+		}
+	}
+}
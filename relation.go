@@ -0,0 +1,221 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// implied returns the option, and all of the options it implies,
+// directly or indirectly.
+func (opt *Option) implied() []*Option {
+	all := []*Option{opt}
+	seen := map[*Option]bool{opt: true}
+	for i := 0; i < len(all); i++ {
+		for _, imp := range all[i].Implies {
+			if !seen[imp] {
+				seen[imp] = true
+				all = append(all, imp)
+			}
+		}
+	}
+	return all
+}
+
+func conflicts(a, b *Option) bool {
+	for _, c := range a.Conflicts {
+		if c == b {
+			return true
+		}
+	}
+	for _, c := range b.Conflicts {
+		if c == a {
+			return true
+		}
+	}
+	return false
+}
+
+// refersTo returns true if the option names other in any of
+// its relations.
+func (opt *Option) refersTo(other *Option) bool {
+	for _, list := range [][]*Option{opt.Requires, opt.Implies, opt.Conflicts} {
+		for _, o := range list {
+			if o == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceRef replaces any references to old in the option's relations
+// with repl.  New slices are allocated, as the caller may share them.
+func (opt *Option) replaceRef(old, repl *Option) {
+	replace := func(list []*Option) []*Option {
+		result := make([]*Option, 0, len(list))
+		for _, o := range list {
+			if o == old {
+				o = repl
+			}
+			result = append(result, o)
+		}
+		return result
+	}
+	if opt.refersTo(old) {
+		opt.Requires = replace(opt.Requires)
+		opt.Implies = replace(opt.Implies)
+		opt.Conflicts = replace(opt.Conflicts)
+	}
+}
+
+// checkRelations verifies that the relationships declared by
+// the option are consistent, and only name options in the set.
+func (opt *Option) checkRelations(set map[*Option]bool) error {
+	for _, list := range [][]*Option{opt.Requires, opt.Implies, opt.Conflicts} {
+		for _, o := range list {
+			if !set[o] {
+				return mkErr(ErrBadRelation, fmt.Sprintf(
+					"%s refers to %s, which has not been added",
+					opt.name(), o.name()))
+			}
+		}
+	}
+	for _, imp := range opt.Implies {
+		if _, ok := imp.ArgP.(*bool); !ok && (imp.HasArg || imp.ArgP != nil) {
+			return mkErr(ErrBadRelation, fmt.Sprintf(
+				"%s implies %s, which takes a value",
+				opt.name(), imp.name()))
+		}
+	}
+	all := append(opt.implied(), opt.Requires...)
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			if a != b && conflicts(a, b) {
+				return mkErr(ErrBadRelation, fmt.Sprintf(
+					"%s needs both %s and %s, which conflict",
+					opt.name(), a.name(), b.name()))
+			}
+		}
+	}
+	return nil
+}
+
+// optionSet returns the set of the given options.
+func optionSet(opts []*Option) map[*Option]bool {
+	set := make(map[*Option]bool, len(opts))
+	for _, opt := range opts {
+		set[opt] = true
+	}
+	return set
+}
+
+// applyRelations applies implications, and then checks requirements
+// and conflicts, for the options that have been seen.  Errors are
+// passed to fail, and processing stops if it returns an error.
+func (o *Options) applyRelations(ctx context.Context, fail func(error) error) error {
+	var queue []*Option
+	for _, opt := range o.allOpts {
+		if opt.Seen {
+			queue = append(queue, opt)
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		opt := queue[i]
+		for _, imp := range opt.Implies {
+			if imp.Seen {
+				continue
+			}
+			val := ""
+			if imp.HasArg || imp.isFlag() {
+				val = "true" // Must be a *bool
+			}
+			src := Source{Kind: SourceImplied, Name: opt.name()}
+			o.trace(TraceEvent{Kind: TraceOption, Option: imp, Name: imp.name(), Index: -1},
+				"%s is implied by %s", imp.name(), opt.name())
+			hc := &HandlerContext{Name: imp.name(), Index: -1, ctx: ctx}
+			e := o.handlerError(o.apply(imp, imp.name(), val, src, hc), imp, imp.name(), src)
+			if e = fail(e); e != nil {
+				return e
+			}
+			queue = append(queue, imp)
+		}
+	}
+	// Options may list each other as conflicts, but each pair is
+	// reported only once.
+	reported := map[[2]*Option]bool{}
+	for _, opt := range o.allOpts {
+		if !opt.Seen {
+			continue
+		}
+		for _, req := range opt.Requires {
+			if req.Seen {
+				continue
+			}
+			if e := fail(&ParseError{
+				Code:   ErrMissingOption,
+				Arg:    req.name(),
+				Option: req,
+				Err:    fmt.Errorf("required by %s", opt.name()),
+				Source: opt.Source,
+			}); e != nil {
+				return e
+			}
+		}
+		for _, c := range opt.Conflicts {
+			if !c.Seen || reported[[2]*Option{opt, c}] || reported[[2]*Option{c, opt}] {
+				continue
+			}
+			reported[[2]*Option{opt, c}] = true
+			if e := fail(&ParseError{
+				Code:   ErrConflictingOptions,
+				Arg:    opt.name(),
+				Option: opt,
+				Err:    fmt.Errorf("conflicts with %s", c.name()),
+				Source: opt.Source,
+			}); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// relationHelp returns the text to append to the option's help
+// describing its relationships.
+func (opt *Option) relationHelp() string {
+	var parts []string
+	add := func(what string, opts []*Option) {
+		if len(opts) == 0 {
+			return
+		}
+		var names []string
+		for _, o := range opts {
+			names = append(names, o.name())
+		}
+		parts = append(parts, what+" "+strings.Join(names, ", "))
+	}
+	add("requires", opt.Requires)
+	add("implies", opt.Implies)
+	add("conflicts with", opt.Conflicts)
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, "; ") + ")"
+}
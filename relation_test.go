@@ -0,0 +1,180 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestRelations_Implies(t *testing.T) {
+	opts := &Options{}
+	var recursive bool
+	handled := false
+	oHidden := &Option{
+		Long:   "include-hidden",
+		Handle: func(string) error { handled = true; return nil },
+	}
+	oRecursive := &Option{Long: "recursive", Short: 'r', ArgP: &recursive}
+	oDeep := &Option{Long: "deep", Implies: []*Option{oRecursive}}
+	oAll := &Option{Long: "all", Implies: []*Option{oHidden, oDeep}}
+	if e := opts.Add(oHidden, oRecursive, oDeep, oAll); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	_ = mustParse(t, opts, []string{"--all"})
+	if !oHidden.Seen || !handled || !oDeep.Seen || !oRecursive.Seen || !recursive {
+		t.Errorf("implications not applied")
+	}
+	if oRecursive.Source.String() != "implied by --deep" ||
+		oHidden.Source.String() != "implied by --all" {
+		t.Errorf("wrong source: %v", oRecursive.Source)
+	}
+
+	// Explicit options are left alone.
+	opts.Reset()
+	recursive = false
+	_ = mustParse(t, opts, []string{"--recursive=false", "--all"})
+	if recursive || oRecursive.Source.Kind != SourceArgs {
+		t.Errorf("explicit value replaced")
+	}
+}
+
+func TestRelations_Requires(t *testing.T) {
+	opts := &Options{}
+	oCert := &Option{Long: "cert", HasArg: true}
+	oKey := &Option{Long: "key", HasArg: true, Requires: []*Option{oCert}}
+	if e := opts.Add(oCert, oKey); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"--key", "k", "--cert", "c"})
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--cert", "c"})
+	opts.Reset()
+	_, e := opts.Parse([]string{"--key", "k"})
+	mustFailAs(t, e, ErrMissingOption)
+	if e.Error() != "missing required option: --cert: required by --key" {
+		t.Errorf("wrong message: %v", e)
+	}
+}
+
+func TestRelations_Conflicts(t *testing.T) {
+	opts := &Options{}
+	oVerbose := &Option{Long: "verbose", Short: 'v'}
+	oQuiet := &Option{Short: 'q', Conflicts: []*Option{oVerbose}}
+	oAll := &Option{Long: "all", Implies: []*Option{oVerbose}}
+	if e := opts.Add(oVerbose, oQuiet, oAll); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"-q"})
+	opts.Reset()
+	_, e := opts.Parse([]string{"-vq"})
+	mustFailAs(t, e, ErrConflictingOptions)
+	if e.Error() != "conflicting options: -q: conflicts with --verbose" {
+		t.Errorf("wrong message: %v", e)
+	}
+	opts.Reset()
+	_, e = opts.Parse([]string{"--all", "-q"})
+	mustFailAs(t, e, ErrConflictingOptions)
+
+	// Options that conflict with each other are reported once.
+	oVerbose.Conflicts = []*Option{oQuiet}
+	opts.CollectErrors = true
+	opts.Reset()
+	_, e = opts.Parse([]string{"-q", "-v"})
+	if e == nil || e.Error() != "conflicting options: --verbose: conflicts with -q" {
+		t.Errorf("wrong errors: %v", e)
+	}
+}
+
+func TestRelations_Bad(t *testing.T) {
+	opts := &Options{}
+	oA := &Option{Long: "a"}
+	oB := &Option{Long: "b", Conflicts: []*Option{oA}}
+	oC := &Option{Long: "c", Implies: []*Option{oA, oB}}
+	mustFailAs(t, opts.Add(oA, oB, oC), ErrBadRelation)
+
+	opts = &Options{}
+	oD := &Option{Long: "d", Requires: []*Option{oB}, Implies: []*Option{oA}}
+	e := opts.Add(oA, oB, oD)
+	mustFailAs(t, e, ErrBadRelation)
+	if e.Error() != "invalid option relationship: "+
+		"--d needs both --a and --b, which conflict" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	opts = &Options{}
+	oV := &Option{Long: "value", HasArg: true}
+	e = opts.Add(oV, &Option{Long: "e", Implies: []*Option{oV}})
+	mustFailAs(t, e, ErrBadRelation)
+	if e.Error() != "invalid option relationship: "+
+		"--e implies --value, which takes a value" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	// Relations must name options in the set.
+	oHidden := &Option{Long: "hidden"}
+	for _, opt := range []*Option{
+		{Long: "all", Implies: []*Option{oHidden}},
+		{Long: "all", Requires: []*Option{oHidden}},
+		{Long: "all", Conflicts: []*Option{oHidden}},
+	} {
+		e = opts.Add(opt)
+		mustFailAs(t, e, ErrBadRelation)
+		if e.Error() != "invalid option relationship: "+
+			"--all refers to --hidden, which has not been added" {
+			t.Errorf("wrong message: %v", e)
+		}
+	}
+	mustAdd(t, opts, oHidden)
+	mustAdd(t, opts, &Option{Long: "all", Implies: []*Option{oHidden}})
+
+	// Including a set brings its options in.
+	sub := &Options{}
+	oCert := &Option{Long: "cert", HasArg: true}
+	mustAdd(t, sub, oCert)
+	mustFailAs(t, opts.Add(&Option{Long: "key", Requires: []*Option{oCert}}), ErrBadRelation)
+	if e = opts.Include(sub, "tls-", ""); e != nil {
+		t.Fatalf("include failed: %v", e)
+	}
+	mustAdd(t, opts, &Option{Long: "key", Requires: []*Option{oCert}})
+}
+
+func TestRelations_Help(t *testing.T) {
+	opts := &Options{}
+	oCert := &Option{Long: "cert", HasArg: true, Help: "Certificate"}
+	oVerbose := &Option{Short: 'v', Help: "Verbose"}
+	oKey := &Option{
+		Long:      "key",
+		HasArg:    true,
+		Help:      "Key",
+		Requires:  []*Option{oCert},
+		Conflicts: []*Option{oVerbose},
+	}
+	oAll := &Option{Long: "all", Help: "All", Implies: []*Option{oVerbose}}
+	if e := opts.Add(oCert, oVerbose, oKey, oAll); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --cert ARG    Certificate
+  -v            Verbose
+  --key ARG     Key (requires --cert; conflicts with -v)
+  --all         All (implies -v)
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
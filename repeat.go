@@ -0,0 +1,116 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+)
+
+// Repeat is a policy for options that are given more than once.
+//
+// Occurrences from different kinds of Source are not considered
+// repeats of each other, so that (for example) the command line
+// can override a value from the environment.
+type Repeat int
+
+// These are the repeat policies.
+const (
+	// RepeatDefault lets the last occurrence win, except for maps and
+	// slices, which accumulate the values from every occurrence.
+	// Values are added to those already in the map or slice, which
+	// Options.Reset does not clear.
+	RepeatDefault Repeat = iota
+
+	// RepeatLast lets the last occurrence win, even for maps and
+	// slices, which are replaced by the value of each occurrence.
+	RepeatLast
+
+	// RepeatFirst lets the first occurrence win.  Later occurrences
+	// are ignored, and Handle is not called for them, but they still
+	// count towards MaxCount.
+	RepeatFirst
+
+	// RepeatAccumulate processes every occurrence, with maps and
+	// slices accumulating values.  At present this is the same as
+	// RepeatDefault, but it is explicit.
+	RepeatAccumulate
+
+	// RepeatError reports a second occurrence as an error,
+	// using ErrRepeatedOption.
+	RepeatError
+)
+
+// checkRepeat applies the repeat policy and MaxCount for an occurrence
+// of the option.  It returns true if the occurrence should be ignored.
+func (opt *Option) checkRepeat(arg string, src Source) (bool, error) {
+	if opt.layer == 0 || src.Kind != opt.first.Kind {
+		opt.layer = 1
+		opt.first = src
+		return false, nil
+	}
+	opt.layer++
+	if opt.Repeat == RepeatError {
+		return false, &ParseError{
+			Code:   ErrRepeatedOption,
+			Arg:    arg,
+			Option: opt,
+			Err:    fmt.Errorf("at %v, already given at %v", src, opt.first),
+			Source: src,
+		}
+	}
+	// MaxCount applies even to occurrences that are ignored.
+	if opt.MaxCount > 0 && opt.layer > opt.MaxCount {
+		return false, &ParseError{
+			Code:   ErrRepeatedOption,
+			Arg:    arg,
+			Option: opt,
+			Err: fmt.Errorf("at %v, may be given at most %d times",
+				src, opt.MaxCount),
+			Source: src,
+		}
+	}
+	if opt.Repeat == RepeatFirst {
+		opt.count++
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkCounts verifies that every option has been given at least
+// MinCount times.  Errors are passed to fail, and checking stops if it
+// returns an error.
+func (o *Options) checkCounts(fail func(error) error) error {
+	for _, opt := range o.allOpts {
+		if opt.count >= opt.MinCount {
+			continue
+		}
+		var e error
+		if opt.MinCount > 1 {
+			e = fmt.Errorf("needs at least %d occurrences, got %d",
+				opt.MinCount, opt.count)
+		}
+		if e = fail(&ParseError{
+			Code:   ErrMissingOption,
+			Arg:    opt.name(),
+			Option: opt,
+			Err:    e,
+		}); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestRepeat_Error(t *testing.T) {
+	opts := &Options{}
+	var output string
+	o := &Option{Long: "output", Short: 'o', ArgP: &output, Repeat: RepeatError}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"-o", "a"})
+	opts.Reset()
+	_, e := opts.Parse([]string{"-o", "a", "x", "--output=b"})
+	if e != nil {
+		t.Errorf("residual should not be parsed")
+	}
+	opts.Reset()
+	_, e = opts.Parse([]string{"-o", "a", "--output=b"})
+	mustFailAs(t, e, ErrRepeatedOption)
+	if e.Error() != "option repeated: --output=b: "+
+		"at argv[2], already given at argv[0]" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	// The command line may override the environment.
+	opts.Reset()
+	if e = opts.Set("output", "env", Source{Kind: SourceEnv}); e != nil {
+		t.Fatalf("set failed: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"-o", "a"})
+	if output != "a" {
+		t.Errorf("did not override")
+	}
+}
+
+func TestRepeat_First(t *testing.T) {
+	opts := &Options{}
+	var output string
+	calls := 0
+	o := &Option{
+		Long:   "output",
+		ArgP:   &output,
+		Repeat: RepeatFirst,
+		Handle: func(string) error { calls++; return nil },
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"--output", "a", "--output", "b", "--output=bad"})
+	if output != "a" || calls != 1 || o.Raw != "a" {
+		t.Errorf("first did not win: %q", output)
+	}
+
+	// Ignored occurrences still count towards MaxCount.
+	o.MaxCount = 2
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--output=a", "--output=b"})
+	opts.Reset()
+	_, e := opts.Parse([]string{"--output=1", "--output=2", "--output=3", "--output=4"})
+	mustFailAs(t, e, ErrRepeatedOption)
+	if e.Error() != "option repeated: --output=3: at argv[2], may be given at most 2 times" {
+		t.Errorf("wrong message: %v", e)
+	}
+}
+
+func TestRepeat_Last(t *testing.T) {
+	opts := &Options{}
+	var list []string
+	var defs map[string]string
+	oList := &Option{Long: "list", ArgP: &list, Repeat: RepeatLast}
+	oDef := &Option{Short: 'D', ArgP: &defs, Repeat: RepeatLast}
+	if e := opts.Add(oList, oDef); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"--list", "a", "--list", "b", "-Da=1", "-Db=2"})
+	if len(list) != 1 || list[0] != "b" {
+		t.Errorf("wrong list: %v", list)
+	}
+	if len(defs) != 1 || defs["b"] != "2" {
+		t.Errorf("wrong map: %v", defs)
+	}
+}
+
+func TestRepeat_Accumulate(t *testing.T) {
+	opts := &Options{}
+	var list []int
+	o := &Option{Short: 'n', ArgP: &list, Repeat: RepeatAccumulate}
+	mustAdd(t, opts, o)
+	_ = mustParse(t, opts, []string{"-n1", "-n", "2", "-n=3"})
+	if len(list) != 3 || list[0] != 1 || list[1] != 2 || list[2] != 3 {
+		t.Errorf("wrong list: %v", list)
+	}
+}
+
+func TestRepeat_Counts(t *testing.T) {
+	opts := &Options{}
+	oV := &Option{Short: 'v', MaxCount: 3}
+	oName := &Option{Long: "name", HasArg: true, MinCount: 1}
+	oTag := &Option{Long: "tag", HasArg: true, MinCount: 2}
+	if e := opts.Add(oV, oName, oTag); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	_ = mustParse(t, opts, []string{"-vvv", "--name=x", "--tag=a", "--tag=b"})
+
+	opts.Reset()
+	_, e := opts.Parse([]string{"-vvvv", "--name=x", "--tag=a", "--tag=b"})
+	mustFailAs(t, e, ErrRepeatedOption)
+	if e.Error() != "option repeated: -v: at argv[0], may be given at most 3 times" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	opts.Reset()
+	_, e = opts.Parse([]string{"--tag=a", "--tag=b"})
+	mustFailAs(t, e, ErrMissingOption)
+	if e.Error() != "missing required option: --name" {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	opts.Reset()
+	_, e = opts.Parse([]string{"--name=x", "--tag=a"})
+	mustFailAs(t, e, ErrMissingOption)
+	if e.Error() != "missing required option: --tag: needs at least 2 occurrences, got 1" {
+		t.Errorf("wrong message: %v", e)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// redacted is shown in place of the values of secrets.
+const redacted = "<redacted>"
+
+// Secret is a sensitive value, such as a password or token.
+// A *Secret can be used as ArgP, which also marks the option as Secret.
+// The value is never shown: it is redacted when printed, in the output
+// of Options.Config, and in error messages.  Options.Reset zeroes the
+// memory holding it.
+type Secret struct {
+	// FromFile means that the option names a file that the value is
+	// read from, as for --password-file, rather than giving the value
+	// itself.  The name "-" means standard input.  A single trailing
+	// newline is removed.
+	FromFile bool
+
+	value []byte
+	file  string // the file the value was read from
+}
+
+// Bytes returns the value.  This is not a copy, so it will be zeroed
+// when the Secret is reset.
+func (s *Secret) Bytes() []byte {
+	return s.value
+}
+
+// Value returns the value as a string.
+func (s *Secret) Value() string {
+	return string(s.value)
+}
+
+// IsSet returns true if a value has been given.
+func (s *Secret) IsSet() bool {
+	return s.value != nil
+}
+
+// Clear zeroes the value, and forgets it.
+func (s *Secret) Clear() {
+	for i := range s.value {
+		s.value[i] = 0
+	}
+	s.value = nil
+	s.file = ""
+}
+
+func (s *Secret) read(name string) ([]byte, error) {
+	var v []byte
+	var e error
+	if name == "-" {
+		v, e = io.ReadAll(os.Stdin)
+	} else {
+		v, e = os.ReadFile(name)
+	}
+	if e != nil {
+		return nil, pathErr(name, e)
+	}
+	if bytes.HasSuffix(v, []byte("\r\n")) {
+		v = v[:len(v)-2]
+	} else if bytes.HasSuffix(v, []byte("\n")) {
+		v = v[:len(v)-1]
+	}
+	return v, nil
+}
+
+func (s *Secret) convert(val string, _ *Option) (interface{}, func(), error) {
+	v := []byte(val)
+	file := ""
+	if s.FromFile {
+		var e error
+		if v, e = s.read(val); e != nil {
+			return nil, nil, e
+		}
+		file = val
+	}
+	return v, func() {
+		s.Clear()
+		s.value = v
+		s.file = file
+	}, nil
+}
+
+func (s *Secret) reset() {
+	s.Clear()
+}
+
+func (s *Secret) hint() (string, string) {
+	if s.FromFile {
+		return "FILE", `"-" for standard input`
+	}
+	return "SECRET", ""
+}
+
+// String returns a placeholder if the value is set, so that it cannot
+// be printed by accident.
+func (s Secret) String() string {
+	if s.value == nil {
+		return ""
+	}
+	return redacted
+}
+
+// GoString is like String, for the %#v format.
+func (s Secret) GoString() string {
+	return "optopia.Secret{" + redacted + "}"
+}
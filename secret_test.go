@@ -0,0 +1,175 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	opts := &Options{}
+	pw := &Secret{}
+	popt := &Option{Long: "password", Short: 'p', ArgP: pw}
+	mustAdd(t, opts, popt)
+	if !popt.Secret {
+		t.Errorf("option not marked secret")
+	}
+	if pw.IsSet() || pw.String() != "" {
+		t.Errorf("secret set initially")
+	}
+
+	_ = mustParse(t, opts, []string{"--password", "hunter2"})
+	if pw.Value() != "hunter2" || string(pw.Bytes()) != "hunter2" || !pw.IsSet() {
+		t.Errorf("wrong value %q", pw.Value())
+	}
+	if popt.Raw != "" {
+		t.Errorf("raw value recorded: %q", popt.Raw)
+	}
+	for _, s := range []string{
+		fmt.Sprint(pw), fmt.Sprintf("%v", *pw), fmt.Sprintf("%+v", *pw),
+		fmt.Sprintf("%#v", *pw), opts.Config(),
+	} {
+		if strings.Contains(s, "hunter2") || !strings.Contains(s, "<redacted>") {
+			t.Errorf("value not redacted: %s", s)
+		}
+	}
+
+	buf := pw.Bytes()
+	opts.Reset()
+	if pw.IsSet() || string(buf) != "\x00\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("not cleared on reset: %q", buf)
+	}
+
+	// A later value replaces an earlier one, which is zeroed.
+	_ = mustParse(t, opts, []string{"-phunter2", "--password=swordfish"})
+	if pw.Value() != "swordfish" {
+		t.Errorf("wrong value %q", pw.Value())
+	}
+
+	// Errors must not include the value.
+	popt.MaxCount = 1
+	for _, args := range [][]string{
+		{"--password=hunter2", "--password=hunter3"},
+		{"-phunter2", "-phunter3"},
+	} {
+		opts.Reset()
+		_, e := opts.Parse(args)
+		mustFailAs(t, e, ErrRepeatedOption)
+		if e != nil && strings.Contains(e.Error(), "hunter") {
+			t.Errorf("value in error: %v", e)
+		}
+	}
+	popt.MaxCount = 0
+	opts.Reset()
+	_, e := opts.Parse([]string{"-xphunter2"})
+	mustFailAs(t, e, ErrNoSuchOption)
+	if e == nil || e.Error() != "no such option: -xp<redacted>" {
+		t.Errorf("wrong error: %v", e)
+	}
+	if got := opts.Diagnostic(e, []string{"-xphunter2"}, DiagnosticConfig{}); got !=
+		"error: no such option: -xp<redacted>\n  '-xp<redacted>'\n    ^\n" {
+		t.Errorf("wrong diagnostic:\n%s", got)
+	}
+	popt.Validate = func(v interface{}) error {
+		if len(v.([]byte)) < 8 {
+			return errors.New("too short")
+		}
+		return nil
+	}
+	opts.Reset()
+	_, e = opts.Parse([]string{"-phunter2"})
+	if e == nil || e.Error() != "failure parsing option value: -p: too short" {
+		t.Errorf("wrong error: %v", e)
+	}
+
+	// Nor errors from choices, or from the option's own functions.
+	popt.Validate = nil
+	popt.Choices = []string{"hunter1"}
+	var events []TraceEvent
+	opts.Trace = func(ev TraceEvent) { events = append(events, ev) }
+	opts.Reset()
+	args := []string{"--password", "hunter2"}
+	_, e = opts.Parse(args)
+	if e == nil || e.Error() != "failure parsing option value: --password: "+
+		"invalid choice (valid choices: hunter1)" {
+		t.Errorf("wrong error: %v", e)
+	}
+	got := Explain(events) + opts.Diagnostic(e, args, DiagnosticConfig{})
+	if strings.Contains(got, "hunter2") {
+		t.Errorf("value shown: %s", got)
+	}
+	popt.Choices = nil
+	popt.Normalize = func(v string) (string, error) {
+		return "", fmt.Errorf("cannot normalize %q", v)
+	}
+	opts.Reset()
+	_, e = opts.Parse([]string{"-phunter2"})
+	if e == nil || e.Error() != `failure parsing option value: -p: cannot normalize "<redacted>"` {
+		t.Errorf("wrong error: %v", e)
+	}
+}
+
+func TestSecret_File(t *testing.T) {
+	dir := mustTempDir(t)
+	name := filepath.Join(dir, "pw")
+	if e := os.WriteFile(name, []byte("hunter2\n"), 0600); e != nil {
+		t.Fatalf("cannot write file: %v", e)
+	}
+	crlf := filepath.Join(dir, "crlf")
+	if e := os.WriteFile(crlf, []byte("hunter2\r\n\n"), 0600); e != nil {
+		t.Fatalf("cannot write file: %v", e)
+	}
+
+	opts := &Options{}
+	pw := &Secret{FromFile: true}
+	popt := &Option{Long: "password-file", ArgP: pw, Help: "Password"}
+	mustAdd(t, opts, popt)
+
+	_ = mustParse(t, opts, []string{"--password-file", name})
+	if pw.Value() != "hunter2" {
+		t.Errorf("wrong value %q", pw.Value())
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--password-file", crlf})
+	if pw.Value() != "hunter2\r\n" {
+		t.Errorf("wrong value %q", pw.Value())
+	}
+
+	opts.Reset()
+	missing := filepath.Join(dir, "missing")
+	_, e := opts.Parse([]string{"--password-file=" + missing})
+	mustFailAs(t, e, ErrParsingValue)
+	if e != nil && e.Error() != "failure parsing option value: --password-file: "+
+		`"`+missing+`" does not exist` {
+		t.Errorf("wrong message: %v", e)
+	}
+	if pw.IsSet() {
+		t.Errorf("set by failed parse")
+	}
+
+	good := `Options:
+  --password-file FILE    Password ("-" for standard input)
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of bytes.  A *Size can be used as ArgP, accepting
+// values such as 512MiB or 10KB.  The suffixes K, M, G, T, P and E
+// (optionally followed by B) are SI, multiples of 1000, while KiB,
+// MiB and so forth are IEC, multiples of 1024.  Suffixes are not
+// case sensitive, except that E must be upper case (1e would look like
+// an exponent), and the trailing B may be left off the IEC ones.
+// A plain number, or one with just a B, is bytes.  Numbers are decimal.
+// Fractions are permitted, as long as the result is a whole number
+// of bytes.
+type Size uint64
+
+// sizeNumber matches the numbers accepted by Size.
+var sizeNumber = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// sizeUnits are ordered from largest multiplier to smallest,
+// which is what String relies on.
+var sizeUnits = []struct {
+	suffix string
+	mult   uint64
+}{
+	{"EiB", 1 << 60},
+	{"EB", 1e18},
+	{"PiB", 1 << 50},
+	{"PB", 1e15},
+	{"TiB", 1 << 40},
+	{"TB", 1e12},
+	{"GiB", 1 << 30},
+	{"GB", 1e9},
+	{"MiB", 1 << 20},
+	{"MB", 1e6},
+	{"KiB", 1 << 10},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+// sizeSuffixes maps the permitted spellings of the units, in upper
+// case, to their multipliers.  The B may be left off any of them.
+var sizeSuffixes = func() map[string]uint64 {
+	m := map[string]uint64{"": 1}
+	for _, u := range sizeUnits {
+		m[strings.ToUpper(u.suffix)] = u.mult
+		m[strings.ToUpper(strings.TrimSuffix(u.suffix, "B"))] = u.mult
+	}
+	return m
+}()
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Size) UnmarshalText(text []byte) error {
+	val := string(text)
+	num := strings.TrimRight(val, "KMGTPEkmgtpeIiBb")
+	mult, ok := sizeSuffixes[strings.ToUpper(val[len(num):])]
+	if !ok || !sizeNumber.MatchString(num) ||
+		strings.HasPrefix(val[len(num):], "e") {
+		return fmt.Errorf("invalid size %q", val)
+	}
+	r, _ := new(big.Rat).SetString(num)
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(mult)))
+	if !r.IsInt() {
+		return fmt.Errorf("size %q is not a whole number of bytes", val)
+	}
+	if !r.Num().IsUint64() {
+		return fmt.Errorf("size %q out of range [0, %d]",
+			val, uint64(math.MaxUint64))
+	}
+	*s = Size(r.Num().Uint64())
+	return nil
+}
+
+// String returns the size using the largest unit that represents
+// it exactly.  This is a canonical form that can be parsed again.
+func (s Size) String() string {
+	for _, u := range sizeUnits {
+		if s != 0 && uint64(s)%u.mult == 0 {
+			return strconv.FormatUint(uint64(s)/u.mult, 10) + u.suffix
+		}
+	}
+	return "0B"
+}
+
+func (s *Size) hint() (string, string) {
+	return "SIZE", "a size such as 512MiB or 10KB"
+}
+
+// Quantity is a number that may be given with a suffix of k, M, G or T
+// (for thousands, millions, billions or trillions), or as a percentage
+// (so that 50% is 0.5).  A *Quantity can be used as ArgP.
+type Quantity float64
+
+var quantityUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	{"T", 1e12},
+	{"G", 1e9},
+	{"M", 1e6},
+	{"k", 1e3},
+	{"K", 1e3},
+	{"%", 0.01},
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (q *Quantity) UnmarshalText(text []byte) error {
+	val := string(text)
+	num := val
+	mult := 1.0
+	for _, u := range quantityUnits {
+		if strings.HasSuffix(val, u.suffix) {
+			num = strings.TrimSuffix(val, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+	f, e := strconv.ParseFloat(num, 64)
+	if e == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		e = strconv.ErrSyntax
+	}
+	if e == nil && math.IsInf(f*mult, 0) {
+		e = strconv.ErrRange
+	}
+	if errors.Is(e, strconv.ErrRange) {
+		return fmt.Errorf("quantity %q out of range [%g, %g]",
+			val, -math.MaxFloat64, math.MaxFloat64)
+	}
+	if e != nil {
+		return fmt.Errorf("invalid quantity %q", val)
+	}
+	*q = Quantity(f * mult)
+	return nil
+}
+
+// String returns the quantity using the largest suffix that represents
+// it exactly.  This is a canonical form that can be parsed again.
+func (q Quantity) String() string {
+	f := float64(q)
+	for _, u := range quantityUnits[:4] {
+		if n := f / u.mult; f != 0 && n == math.Trunc(n) && n*u.mult == f &&
+			math.Abs(n) < 1e15 {
+			return strconv.FormatFloat(n, 'f', -1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (q *Quantity) hint() (string, string) {
+	return "NUM", "a number such as 10k or 50%"
+}
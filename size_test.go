@@ -0,0 +1,124 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	opts := &Options{}
+	var val Size
+	mustAdd(t, opts, &Option{Long: "cache-size", ArgP: &val})
+
+	for _, c := range []struct {
+		in   string
+		want Size
+		str  string
+	}{
+		{"512MiB", 512 << 20, "512MiB"},
+		{"512mib", 512 << 20, "512MiB"},
+		{"2Gi", 2 << 30, "2GiB"},
+		{"10k", 10000, "10KB"},
+		{"10KB", 10000, "10KB"},
+		{"1.5KiB", 1536, "1536B"},
+		{"1.5G", 1500000000, "1500MB"},
+		{"100", 100, "100B"},
+		{"100B", 100, "100B"},
+		{"0", 0, "0B"},
+		{"1T", 1e12, "1TB"},
+		{"16EiB", 0, ""},
+		{"15EiB", 15 << 60, "15EiB"},
+		{"1E", 1e18, "1EB"},
+	} {
+		opts.Reset()
+		_, e := opts.Parse([]string{"--cache-size", c.in})
+		if c.str == "" {
+			mustFailAs(t, e, ErrParsingValue)
+			continue
+		}
+		if e != nil {
+			t.Errorf("%s: parse failed: %v", c.in, e)
+			continue
+		}
+		if val != c.want || val.String() != c.str {
+			t.Errorf("%s: got %d (%s), want %d (%s)",
+				c.in, val, val, c.want, c.str)
+		}
+		var again Size
+		if e = again.UnmarshalText([]byte(val.String())); e != nil || again != val {
+			t.Errorf("%s: did not round trip", c.in)
+		}
+	}
+	for _, bad := range []string{"", "MiB", "-1K", "1.5B", "10X", "1KiBB", "junk",
+		"1/2KiB", "0x10", "1e", "1e3", "1eib", "1.", ".5K", "+1K", " 1K",
+		"1KiBi", "1MMi", "1KGi", "1kki", "1iB", "1i", "1BB", "1KBi"} {
+		opts.Reset()
+		mustNotParse(t, opts, []string{"--cache-size", bad})
+	}
+	opts.Reset()
+	_, e := opts.Parse([]string{"--cache-size", "0.1"})
+	if e == nil || e.Error() != `failure parsing option value: --cache-size: `+
+		`size "0.1" is not a whole number of bytes` {
+		t.Errorf("wrong error: %v", e)
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	opts := &Options{}
+	var val Quantity
+	mustAdd(t, opts, &Option{Long: "limit", ArgP: &val, Help: "Limit"})
+
+	for _, c := range []struct {
+		in   string
+		want Quantity
+		str  string
+	}{
+		{"10k", 10000, "10k"},
+		{"10K", 10000, "10k"},
+		{"2.5M", 2500000, "2500k"},
+		{"3G", 3e9, "3G"},
+		{"1T", 1e12, "1T"},
+		{"50%", 0.5, "0.5"},
+		{"-2k", -2000, "-2k"},
+		{"1500", 1500, "1500"},
+		{"0.25", 0.25, "0.25"},
+		{"0", 0, "0"},
+	} {
+		opts.Reset()
+		_ = mustParse(t, opts, []string{"--limit", c.in})
+		if val != c.want || val.String() != c.str {
+			t.Errorf("%s: got %v (%s), want %v (%s)",
+				c.in, float64(val), val, float64(c.want), c.str)
+		}
+		var again Quantity
+		if e := again.UnmarshalText([]byte(val.String())); e != nil || again != val {
+			t.Errorf("%s: did not round trip", c.in)
+		}
+	}
+	for _, bad := range []string{"", "k", "10x", "NaN", "Inf", "1e308T"} {
+		opts.Reset()
+		mustNotParse(t, opts, []string{"--limit", bad})
+	}
+
+	good := `Options:
+  --limit NUM    Limit (a number such as 10k or 50%)
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SourceKind identifies the kind of place an option value came from.
+type SourceKind int
+
+// These are the kinds of sources.
+const (
+	SourceDefault SourceKind = iota // not set, so the default value
+	SourceEnv                       // an environment variable
+	SourceFile                      // a configuration file
+	SourceArgs                      // the command line arguments
+	SourceImplied                   // implied by another option
+)
+
+// Source records where the value of an option came from.
+type Source struct {
+	// Kind is the kind of source.
+	Kind SourceKind
+
+	// Name is the name of the environment variable, or of the file,
+	// or of the option that implied this one.
+	Name string
+
+	// Line is the line number within the file, if known.
+	Line int
+
+	// Index is the index of the option within the arguments
+	// passed to Options.Parse.
+	Index int
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceEnv:
+		return "env " + s.Name
+	case SourceFile:
+		if s.Line > 0 {
+			return fmt.Sprintf("%s:%d", s.Name, s.Line)
+		}
+		return s.Name
+	case SourceArgs:
+		return fmt.Sprintf("argv[%d]", s.Index)
+	case SourceImplied:
+		return "implied by " + s.Name
+	}
+	return "default"
+}
+
+// Occurrence records a single occurrence of an option.
+type Occurrence struct {
+	// Option is the option that was given.
+	Option *Option
+
+	// Name is the option as it was spelled, for example "-v"
+	// or "--verbose".
+	Name string
+
+	// Value is the raw value given, before any normalization.  It is
+	// empty for options that do not take a value, and for Secret
+	// options.  For flags given without a value, it is "true".
+	Value string
+
+	// Index is the index of the argument holding the option, within
+	// the arguments passed to Parse, or -1 if it was not given in the
+	// arguments.
+	Index int
+
+	// Source records where the occurrence came from.
+	Source Source
+
+	value place // where the value is, for the arguments to Parse
+}
+
+// Occurrences returns every occurrence of the options since the last
+// call to Reset, in the order they were processed.  This includes
+// those given with Set, and those implied by other options, as well as
+// any ignored because of the Repeat policy.  Each Option also records
+// its own occurrences.
+func (o *Options) Occurrences() []Occurrence {
+	return append([]Occurrence(nil), o.occurrences...)
+}
+
+// record records an occurrence of the option.
+func (o *Options) record(opt *Option, val string, src Source, hc *HandlerContext) {
+	occ := Occurrence{
+		Option: opt,
+		Name:   hc.Name,
+		Index:  hc.Index,
+		Source: src,
+		value:  hc.value,
+	}
+	if (opt.HasArg || opt.isFlag()) && !opt.Secret {
+		occ.Value = val
+	}
+	opt.Occurrences = append(opt.Occurrences, occ)
+	o.occurrences = append(o.occurrences, occ)
+}
+
+// Set applies a value to the named option, as though it had been
+// given on the command line, but recording src as where it came from.
+// This is intended for use by code that takes option values from
+// environment variables or configuration files.  The name is the long
+// name of the option, or its short name.  The value is ignored for
+// options that do not take one.  For flags (see ArgP), an empty value
+// means true.
+func (o *Options) Set(name string, val string, src Source) error {
+	o.init()
+	opt := o.longOpts[name]
+	if opt == nil {
+		if r := []rune(name); len(r) == 1 {
+			opt = o.shortOpts[r[0]]
+		}
+	}
+	if opt == nil {
+		return &ParseError{Code: ErrNoSuchOption, Arg: name}
+	}
+	if opt.isFlag() && val == "" {
+		val = "true"
+	} else if !opt.HasArg && !opt.isFlag() {
+		val = ""
+	}
+	o.trace(TraceEvent{Kind: TraceOption, Option: opt, Name: name, Index: -1},
+		"%s is given by %v", opt.name(), src)
+	hc := &HandlerContext{
+		Name:  opt.name(),
+		Index: -1,
+		ctx:   context.Background(),
+	}
+	return o.apply(opt, fmt.Sprintf("%s (%v)", opt.name(), src), val, src, hc)
+}
+
+// Config returns a description of the effective configuration, listing
+// the value of each registered option along with where that value came
+// from.  The values of Secret options are redacted.  This is intended
+// for debugging, for example to implement a --print-config option.
+func (o *Options) Config() string {
+	type line struct {
+		name  string
+		value string
+		src   string
+	}
+	var lines []line
+	nameLen := 0
+	valLen := 0
+	for _, opt := range o.allOpts {
+		l := line{
+			name:  opt.name(),
+			value: opt.valueString(),
+			src:   opt.Source.String(),
+		}
+		if opt.Secret && l.value != "" {
+			l.value = redacted
+		}
+		if len(l.name) > nameLen {
+			nameLen = len(l.name)
+		}
+		if len(l.value) > valLen {
+			valLen = len(l.value)
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	result := &strings.Builder{}
+	_, _ = result.WriteString("Configuration:\n")
+	for _, l := range lines {
+		_, _ = fmt.Fprintf(result, "  %-*s    %-*s    %s\n",
+			nameLen, l.name, valLen, l.value, l.src)
+	}
+	return result.String()
+}
+
+// name returns the name of the option as it would be used on the
+// command line, preferring the long form.
+func (opt *Option) name() string {
+	if opt.Long != "" {
+		return "--" + opt.longName()
+	}
+	return "-" + string(opt.Short)
+}
+
+// longName returns the long name, including any prefix from Include.
+func (opt *Option) longName() string {
+	if opt.Long == "" {
+		return ""
+	}
+	return opt.prefix + opt.Long
+}
+
+// valueString returns the current value of the option as a string.
+func (opt *Option) valueString() string {
+	if opt.ArgP != nil {
+		return formatValue(opt.ArgP)
+	}
+	if opt.HasArg {
+		return opt.Raw
+	}
+	if opt.Seen {
+		return "true"
+	}
+	return "false"
+}
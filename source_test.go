@@ -0,0 +1,182 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSource_String(t *testing.T) {
+	for _, c := range []struct {
+		src  Source
+		want string
+	}{
+		{Source{}, "default"},
+		{Source{Kind: SourceEnv, Name: "PORT"}, "env PORT"},
+		{Source{Kind: SourceFile, Name: "app.conf", Line: 12}, "app.conf:12"},
+		{Source{Kind: SourceFile, Name: "app.conf"}, "app.conf"},
+		{Source{Kind: SourceArgs, Index: 3}, "argv[3]"},
+	} {
+		if got := c.src.String(); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestOptions_Sources(t *testing.T) {
+	opts := &Options{}
+	port := 80
+	var user string
+	oPort := &Option{Long: "port", ArgP: &port}
+	oUser := &Option{Long: "user", Short: 'u', ArgP: &user}
+	oVerbose := &Option{Short: 'v'}
+	oHost := &Option{Long: "host", HasArg: true}
+	if e := opts.Add(oPort, oUser, oVerbose, oHost); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+
+	if e := opts.Set("user", "bob", Source{Kind: SourceEnv, Name: "USER"}); e != nil {
+		t.Fatalf("Failed set: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"-v", "--host", "example.com"})
+
+	if oPort.Source.Kind != SourceDefault || port != 80 {
+		t.Errorf("port wrong")
+	}
+	if oUser.Source.String() != "env USER" || user != "bob" {
+		t.Errorf("user wrong")
+	}
+	if oVerbose.Source.String() != "argv[0]" {
+		t.Errorf("verbose wrong: %v", oVerbose.Source)
+	}
+	if oHost.Source.String() != "argv[1]" {
+		t.Errorf("host wrong: %v", oHost.Source)
+	}
+
+	opts.Reset()
+	if oUser.Source.Kind != SourceDefault {
+		t.Errorf("did not reset source")
+	}
+
+	e := opts.Set("u", "alice", Source{Kind: SourceFile, Name: "a.conf", Line: 2})
+	if e != nil || user != "alice" || oUser.Source.String() != "a.conf:2" {
+		t.Errorf("short set failed: %v", e)
+	}
+	e = opts.Set("port", "x", Source{Kind: SourceEnv, Name: "PORT"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != "failure parsing option value: --port (env PORT): invalid syntax" {
+		t.Errorf("wrong message: %v", e)
+	}
+	mustFailAs(t, opts.Set("bogus", "", Source{}), ErrNoSuchOption)
+}
+
+func TestOptions_Config(t *testing.T) {
+	opts := &Options{}
+	if opts.Config() != "" {
+		t.Fatalf("not empty string")
+	}
+	port := 80
+	var password string
+	var labels map[string]string
+	e := opts.Add(
+		&Option{Long: "port", ArgP: &port},
+		&Option{Long: "password", ArgP: &password, Secret: true},
+		&Option{Long: "label", ArgP: &labels},
+		&Option{Short: 'v'},
+		&Option{Long: "name", HasArg: true},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	_ = mustParse(t, opts, []string{"--password", "hunter2", "-v",
+		"--label", "b=2", "--label", "a=1"})
+	good := `Configuration:
+  --port        80            default
+  --password    <redacted>    argv[0]
+  --label       a=1,b=2       argv[5]
+  -v            true          argv[2]
+  --name                      default
+`
+	if out := opts.Config(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
+
+func TestOptions_Occurrences(t *testing.T) {
+	opts := &Options{}
+	var exprs []string
+	var verbose bool
+	var first string
+	oExpr := &Option{Long: "expr", Short: 'e', ArgP: &exprs}
+	oVerbose := &Option{Long: "verbose", Short: 'v', ArgP: &verbose}
+	oFirst := &Option{Long: "first", ArgP: &first, Repeat: RepeatFirst}
+	oPass := &Option{Short: 'p', ArgP: &Secret{}}
+	oAll := &Option{Long: "all", Implies: []*Option{oVerbose}}
+	if e := opts.Add(oExpr, oVerbose, oFirst, oPass, oAll); e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	if e := opts.Set("expr", "env", Source{Kind: SourceEnv, Name: "EXPR"}); e != nil {
+		t.Fatalf("Failed set: %v", e)
+	}
+	_ = mustParse(t, opts, []string{
+		"-e", "a", "--expr=b", "-ve", "c", "--first", "x", "--first=y",
+		"-p", "pw", "--verbose=false", "--all",
+	})
+
+	var got []string
+	for _, occ := range opts.Occurrences() {
+		got = append(got, fmt.Sprintf("%s %q %d %v",
+			occ.Name, occ.Value, occ.Index, occ.Source))
+	}
+	want := []string{
+		`--expr "env" -1 env EXPR`,
+		`-e "a" 0 argv[0]`,
+		`--expr "b" 2 argv[2]`,
+		`-v "true" 3 argv[3]`,
+		`-e "c" 3 argv[3]`,
+		`--first "x" 5 argv[5]`,
+		`--first "y" 7 argv[7]`,
+		`-p "" 8 argv[8]`,
+		`--verbose "false" 10 argv[10]`,
+		`--all "" 11 argv[11]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong occurrences:\n%s", strings.Join(got, "\n"))
+	}
+	if first != "x" || len(oFirst.Occurrences) != 2 {
+		t.Errorf("wrong first: %q", first)
+	}
+	if len(oExpr.Occurrences) != 4 || oExpr.Occurrences[3].Value != "c" ||
+		oExpr.Occurrences[3].Option != oExpr {
+		t.Errorf("wrong option occurrences: %v", oExpr.Occurrences)
+	}
+
+	opts.Reset()
+	if len(opts.Occurrences()) != 0 || len(oExpr.Occurrences) != 0 {
+		t.Errorf("occurrences not reset")
+	}
+
+	// Implied options are recorded, with the option that implied them.
+	_ = mustParse(t, opts, []string{"--all"})
+	occs := opts.Occurrences()
+	if len(occs) != 2 || occs[1].Option != oVerbose || occs[1].Index != -1 ||
+		occs[1].Source.String() != "implied by --all" {
+		t.Errorf("wrong implied occurrence: %v", occs)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayouts are used when a Time has no Layouts.
+// These are RFC 3339 date-times, with or without the zone, and
+// RFC 3339 full dates.
+var defaultTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Time is used as an ArgP for options that take a point in time.
+// (A *time.Time can also be used directly as ArgP; that behaves as
+// a Time with the defaults for everything but the Target.)
+//
+// Besides times matching one of the layouts, these relative times are
+// accepted: "now", "today", "yesterday" and "tomorrow" (the latter three
+// being midnight), and a signed duration such as "-2h" or "+30m", which
+// is relative to now.
+type Time struct {
+	// Target is where the time is stored.
+	Target *time.Time
+
+	// Layouts are the layouts, as for time.Parse, that are accepted.
+	// The first one that matches is used.  If empty, RFC 3339
+	// date-times and full dates are accepted.
+	Layouts []string
+
+	// Location is the time zone used for times that do not include
+	// one, and for relative times.  If nil, local time is used.
+	Location *time.Location
+
+	// Now returns the current time, which relative times are based on.
+	// If nil, time.Now is used.  This is mainly useful for testing.
+	Now func() time.Time
+}
+
+func (t *Time) parse(val string) (time.Time, error) {
+	loc := t.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	layouts := t.Layouts
+	if len(layouts) == 0 {
+		layouts = defaultTimeLayouts
+	}
+	for _, layout := range layouts {
+		if tm, e := time.ParseInLocation(layout, val, loc); e == nil {
+			return tm, nil
+		}
+	}
+
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	n := now().In(loc)
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, loc)
+	switch strings.ToLower(val) {
+	case "now":
+		return n, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if strings.HasPrefix(val, "-") || strings.HasPrefix(val, "+") {
+		if d, e := time.ParseDuration(val); e == nil {
+			return n.Add(d), nil
+		}
+	}
+	_, desc := t.hint()
+	return time.Time{}, fmt.Errorf("invalid time %q, expected %s", val, desc)
+}
+
+func (t *Time) convert(val string, _ *Option) (interface{}, func(), error) {
+	tm, e := t.parse(val)
+	if e != nil {
+		return nil, nil, e
+	}
+	return tm, func() { *t.Target = tm }, nil
+}
+
+func (t *Time) hint() (string, string) {
+	if len(t.Layouts) == 0 {
+		return "TIME", "RFC 3339 time or date, or relative such as -2h or yesterday"
+	}
+	return "TIME", "format " + strings.Join(t.Layouts, " or ")
+}
+
+func (t *Time) String() string {
+	if t.Target == nil {
+		return ""
+	}
+	layout := time.RFC3339Nano
+	if len(t.Layouts) > 0 {
+		layout = t.Layouts[0]
+	}
+	return t.Target.Format(layout)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTime_Duration(t *testing.T) {
+	opts := &Options{}
+	var val time.Duration
+	mustAdd(t, opts, &Option{Long: "timeout", ArgP: &val})
+	_ = mustParse(t, opts, []string{"--timeout", "1m30s"})
+	if val != 90*time.Second {
+		t.Errorf("wrong duration %v", val)
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--timeout", "30"})
+	if formatValue(&val) != "1m30s" {
+		t.Errorf("wrong format %s", formatValue(&val))
+	}
+}
+
+func TestTime_Default(t *testing.T) {
+	opts := &Options{}
+	var val time.Time
+	mustAdd(t, opts, &Option{Long: "since", ArgP: &val})
+
+	_ = mustParse(t, opts, []string{"--since", "2024-01-02T03:04:05Z"})
+	if !val.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("wrong time %v", val)
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--since", "2024-01-02"})
+	if val.Year() != 2024 || val.Day() != 2 || val.Location() != time.Local {
+		t.Errorf("wrong date %v", val)
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"--since", "-1h"})
+	if d := time.Since(val); d < time.Hour || d > time.Hour+time.Minute {
+		t.Errorf("wrong relative time %v", val)
+	}
+	opts.Reset()
+	_, e := opts.Parse([]string{"--since", "junk"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: --since: invalid time "junk", `+
+		`expected RFC 3339 time or date, or relative such as -2h or yesterday` {
+		t.Errorf("wrong message: %v", e)
+	}
+}
+
+func TestTime_Wrapper(t *testing.T) {
+	opts := &Options{}
+	var val time.Time
+	loc := time.FixedZone("test", -5*3600)
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	o := &Option{
+		Long: "at",
+		ArgP: &Time{
+			Target:   &val,
+			Layouts:  []string{"2006-01-02 15:04"},
+			Location: loc,
+			Now:      func() time.Time { return now },
+		},
+		Validate: func(v interface{}) error {
+			if v.(time.Time).After(now) {
+				return ErrInvalidOptions
+			}
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+
+	for _, c := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-01-02 03:04", time.Date(2024, 1, 2, 3, 4, 0, 0, loc)},
+		{"now", now},
+		{"today", time.Date(2024, 3, 10, 0, 0, 0, 0, loc)},
+		{"Yesterday", time.Date(2024, 3, 9, 0, 0, 0, 0, loc)},
+		{"-2h", now.Add(-2 * time.Hour)},
+	} {
+		opts.Reset()
+		_ = mustParse(t, opts, []string{"--at", c.in})
+		if !val.Equal(c.want) {
+			t.Errorf("%s: got %v, want %v", c.in, val, c.want)
+		}
+	}
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--at", "tomorrow"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"--at", "2024-01-02T03:04:05Z"})
+	if formatValue(o.ArgP) != "2024-03-10 08:30" {
+		t.Errorf("wrong format %s", formatValue(o.ArgP))
+	}
+}
+
+func TestTime_Help(t *testing.T) {
+	opts := &Options{}
+	var d time.Duration
+	var since, until time.Time
+	e := opts.Add(
+		&Option{Long: "timeout", ArgP: &d, Help: "Timeout"},
+		&Option{Long: "since", ArgP: &since, Help: "Start"},
+		&Option{Long: "until", ArgName: "WHEN", Help: "End",
+			ArgP: &Time{Target: &until, Layouts: []string{"2006-01-02"}}},
+	)
+	if e != nil {
+		t.Fatalf("Failed add: %v", e)
+	}
+	good := `Options:
+  --timeout DURATION    Timeout (a duration such as 30s or 1h15m)
+  --since TIME          Start (RFC 3339 time or date, or relative such as -2h or yesterday)
+  --until WHEN          End (format 2006-01-02)
+`
+	if out := opts.Help(); out != good {
+		t.Fatalf("result does not match:\n%s", out)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// TraceKind identifies the kind of decision a TraceEvent reports.
+type TraceKind int
+
+// These are the kinds of trace events.
+const (
+	TraceOption  TraceKind = iota + 1 // an option was recognized, or not
+	TraceCluster                      // a cluster of short options was found
+	TraceSplit                        // a value was found attached to an option
+	TraceValue                        // the next argument was taken as a value
+	TraceIgnore                       // an occurrence was ignored (RepeatFirst)
+	TraceConvert                      // a value was converted and stored
+	TraceHandler                      // Handle or HandleContext was called
+	TraceEnd                          // option processing ended
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceOption:
+		return "option"
+	case TraceCluster:
+		return "cluster"
+	case TraceSplit:
+		return "split"
+	case TraceValue:
+		return "value"
+	case TraceIgnore:
+		return "ignore"
+	case TraceConvert:
+		return "convert"
+	case TraceHandler:
+		return "handler"
+	case TraceEnd:
+		return "end"
+	}
+	return fmt.Sprintf("TraceKind(%d)", int(k))
+}
+
+// TraceEvent reports a decision made while parsing.  The values of
+// Secret options are redacted.
+type TraceEvent struct {
+	// Kind is the kind of decision.
+	Kind TraceKind
+
+	// Message describes the decision, for people.
+	Message string
+
+	// Option is the option concerned, if any.
+	Option *Option
+
+	// Name is the option or cluster as it was spelled, if any.
+	Name string
+
+	// Value is the value concerned, if any, as it was given.
+	Value string
+
+	// Result is the value stored, for TraceConvert, as shown by
+	// Options.Config.
+	Result string
+
+	// Index is the index of the argument concerned, within the
+	// arguments passed to Parse, or -1 if it was not in the arguments.
+	// For TraceEnd, it is the index of the argument that ended option
+	// processing, if any.
+	Index int
+
+	// Err is the error that resulted, if any.
+	Err error
+}
+
+func (ev TraceEvent) String() string {
+	return ev.Message
+}
+
+// trace reports an event to the Trace function, if there is one,
+// formatting the message only if needed.
+func (o *Options) trace(ev TraceEvent, format string, args ...interface{}) {
+	if o.Trace == nil {
+		return
+	}
+	ev.Message = fmt.Sprintf(format, args...)
+	o.Trace(ev)
+}
+
+// traceValue returns the value to report for the option.
+func traceValue(opt *Option, val string) string {
+	if opt != nil && opt.Secret && val != "" {
+		return redacted
+	}
+	return val
+}
+
+// traceArgs describes how an option takes a value.
+func (opt *Option) traceArgs() string {
+	switch {
+	case opt.isFlag():
+		return "a flag"
+	case opt.HasArg:
+		return "taking a value"
+	}
+	return "without a value"
+}
+
+// redactCluster returns the cluster of short options to report, in
+// traces and errors, with any value for a Secret option redacted.  It
+// returns false if the argument is a single option, perhaps with a value.
+func (o *Options) redactCluster(arg string) (string, bool) {
+	n := 0
+	for i, r := range arg[1:] {
+		n++
+		opt := o.shortOpts[r]
+		end := 1 + i + utf8.RuneLen(r)
+		switch {
+		case opt == nil || end == len(arg):
+		case opt.Secret:
+			return arg[:end] + redacted, n > 1
+		case opt.HasArg:
+			return arg, n > 1
+		case opt.isFlag() && arg[end] == '=' && o.shortOpts['='] == nil:
+			return arg, n > 1
+		}
+	}
+	return arg, n > 1
+}
+
+// traceSplit reports a value attached to a short option.
+func (p *parser) traceSplit(opt *Option, spelling, val string, index int) {
+	p.o.trace(TraceEvent{
+		Kind:   TraceSplit,
+		Option: opt,
+		Name:   spelling,
+		Value:  traceValue(opt, val),
+		Index:  index,
+	}, "the value %q is attached to %s", traceValue(opt, val), spelling)
+}
+
+// traceValue reports the next argument being taken as a value.
+func (p *parser) traceValue(opt *Option, spelling, val string) {
+	p.o.trace(TraceEvent{
+		Kind:   TraceValue,
+		Option: opt,
+		Name:   spelling,
+		Value:  traceValue(opt, val),
+		Index:  p.pos,
+	}, "%q is the value of %s", traceValue(opt, val), spelling)
+}
+
+// traceHandler reports a call to one of the handlers of the option.
+func (o *Options) traceHandler(opt *Option, handler, val string, index int, e error) {
+	ev := TraceEvent{
+		Kind:   TraceHandler,
+		Option: opt,
+		Name:   handler,
+		Value:  traceValue(opt, val),
+		Index:  index,
+		Err:    e,
+	}
+	if e != nil {
+		o.trace(ev, "%s: %s failed: %v", opt.name(), handler, e)
+	} else {
+		o.trace(ev, "%s: %s called", opt.name(), handler)
+	}
+}
+
+// TraceLogger returns a function, for use as Options.Trace, that logs
+// each event to the logger at the given level.  The message is that of
+// the event, with attributes for its details.
+func TraceLogger(l *slog.Logger, level slog.Level) func(TraceEvent) {
+	return func(ev TraceEvent) {
+		attrs := []slog.Attr{slog.String("kind", ev.Kind.String())}
+		if ev.Option != nil {
+			attrs = append(attrs, slog.String("option", ev.Option.name()))
+		}
+		if ev.Name != "" {
+			attrs = append(attrs, slog.String("name", ev.Name))
+		}
+		if ev.Value != "" {
+			attrs = append(attrs, slog.String("value", ev.Value))
+		}
+		if ev.Result != "" {
+			attrs = append(attrs, slog.String("result", ev.Result))
+		}
+		if ev.Index >= 0 {
+			attrs = append(attrs, slog.Int("index", ev.Index))
+		}
+		if ev.Err != nil {
+			attrs = append(attrs, slog.Any("error", ev.Err))
+		}
+		l.LogAttrs(context.Background(), level, ev.Message, attrs...)
+	}
+}
+
+// Explain returns an account of how arguments were interpreted, given
+// the events reported to Options.Trace while they were parsed.  The
+// events are listed in order, grouped by the argument they concern.
+// For example:
+//
+//	argv[0]:
+//	  -vp is a cluster of short options
+//	  -v is option --verbose, a flag
+//	  --verbose: "true" stored as true
+//	  -p is option --port, taking a value
+//	argv[1]:
+//	  "80" is the value of -p
+//	  --port: "80" stored as 80
+//	argv[2]:
+//	  "file" is not an option, which ends the options
+func Explain(events []TraceEvent) string {
+	result := &strings.Builder{}
+	index := -1
+	for _, ev := range events {
+		if ev.Index >= 0 && ev.Index != index {
+			_, _ = fmt.Fprintf(result, "argv[%d]:\n", ev.Index)
+		}
+		index = ev.Index
+		if index >= 0 {
+			_, _ = result.WriteString("  ")
+		}
+		_, _ = result.WriteString(ev.Message + "\n")
+	}
+	return result.String()
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Trace(t *testing.T) {
+	var events []TraceEvent
+	var verbose bool
+	var port int
+	var name string
+	opts := &Options{Trace: func(ev TraceEvent) { events = append(events, ev) }}
+	oQuiet := &Option{Long: "quiet", Short: 'q'}
+	mustAdd(t, opts, &Option{Long: "verbose", Short: 'v', ArgP: &verbose})
+	mustAdd(t, opts, &Option{Long: "port", Short: 'p', ArgP: &port,
+		Handle: func(string) error { return nil }})
+	mustAdd(t, opts, &Option{Long: "name", ArgP: &name, Repeat: RepeatFirst})
+	mustAdd(t, opts, &Option{Long: "password", Short: 'P', ArgP: &Secret{}})
+	mustAdd(t, opts, oQuiet)
+	mustAdd(t, opts, &Option{Long: "all", Implies: []*Option{oQuiet},
+		HandleContext: func(hc *HandlerContext) error {
+			if len(hc.Args()) > 0 {
+				return errors.New("no")
+			}
+			return nil
+		}})
+	_ = mustParse(t, opts, []string{
+		"-vp", "0x50", "--name=a", "--name", "b", "-Phunter2", "--password", "swordfish",
+		"-v=n", "--", "-x",
+	})
+	want := `argv[0]:
+  -vp is a cluster of short options
+  -v is option --verbose, a flag
+  --verbose: "true" stored as true
+  -p is option --port, taking a value
+argv[1]:
+  "0x50" is the value of -p
+  --port: "0x50" stored as 80
+  --port: Handle called
+argv[2]:
+  --name is option --name, taking a value
+  the value "a" is attached to --name
+  --name: "a" stored as a
+argv[3]:
+  --name is option --name, taking a value
+argv[4]:
+  "b" is the value of --name
+argv[3]:
+  --name is ignored, as --name was already given
+argv[5]:
+  -P is option --password, taking a value
+  the value "<redacted>" is attached to -P
+  --password: "<redacted>" stored as <redacted>
+argv[6]:
+  --password is option --password, taking a value
+argv[7]:
+  "<redacted>" is the value of --password
+  --password: "<redacted>" stored as <redacted>
+argv[8]:
+  -v is option --verbose, a flag
+  the value "n" is attached to -v
+  --verbose: "n" stored as false
+argv[9]:
+  "--" ends the options
+`
+	if got := Explain(events); got != want {
+		t.Errorf("wrong explanation:\n%s", got)
+	}
+	for _, ev := range events {
+		if strings.Contains(ev.Message+ev.Value+ev.Result+ev.Name, "hunter2") ||
+			strings.Contains(ev.Message+ev.Value+ev.Result+ev.Name, "swordfish") {
+			t.Errorf("secret in %v", ev)
+		}
+	}
+
+	// Clusters with secrets attached, handlers, implied options and Set.
+	opts.Reset()
+	events = nil
+	_, e := opts.Parse([]string{"-vPhunter2", "--all", "file"})
+	if e == nil || e.Error() != "no" {
+		t.Errorf("wrong error: %v", e)
+	}
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-v"})
+	if e := opts.Set("port", "1", Source{Kind: SourceEnv, Name: "PORT"}); e != nil {
+		t.Fatalf("set failed: %v", e)
+	}
+	want = `argv[0]:
+  -vP<redacted> is a cluster of short options
+`
+	if got := Explain(events[:1]); got != want {
+		t.Errorf("wrong explanation:\n%s", got)
+	}
+	want = `argv[1]:
+  --all is option --all, without a value
+  --all: HandleContext failed: no
+argv[0]:
+  -v is option --verbose, a flag
+  --verbose: "true" stored as true
+there are no more arguments
+--port is given by env PORT
+--port: "1" stored as 1
+--port: Handle called
+`
+	if got := Explain(events[6:]); got != want {
+		t.Errorf("wrong explanation:\n%s", got)
+	}
+	if events[0].Name != "-vP<redacted>" || events[7].Err == nil ||
+		events[7].Kind != TraceHandler || events[7].Name != "HandleContext" {
+		t.Errorf("wrong events: %v", events)
+	}
+
+	opts.Reset()
+	events = nil
+	_ = mustParse(t, opts, []string{"--all"})
+	if got := Explain(events); !strings.Contains(got, "there are no more arguments\n--quiet is implied by --all\n") {
+		t.Errorf("wrong explanation:\n%s", got)
+	}
+}
+
+func TestTraceLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	var port int
+	opts := &Options{Trace: TraceLogger(l, slog.LevelDebug)}
+	mustAdd(t, opts, &Option{Long: "port", ArgP: &port})
+	_, _ = opts.Parse([]string{"--port=x"})
+	want := `level=DEBUG msg="--port is option --port, taking a value" kind=option option=--port name=--port index=0
+level=DEBUG msg="the value \"x\" is attached to --port" kind=split option=--port name=--port value=x index=0
+level=DEBUG msg="--port: \"x\" could not be stored: strconv.ParseInt: parsing \"x\": invalid syntax" ` +
+		`kind=convert option=--port value=x index=0 error="strconv.ParseInt: parsing \"x\": invalid syntax"
+`
+	if buf.String() != want {
+		t.Errorf("wrong log:\n%s", buf.String())
+	}
+}
+
+func TestTraceKind_String(t *testing.T) {
+	if TraceEnd.String() != "end" || TraceKind(0).String() != "TraceKind(0)" {
+		t.Errorf("wrong names")
+	}
+}
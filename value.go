@@ -0,0 +1,445 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"encoding"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Map is used as an ArgP for options that take key=value pairs,
+// such as --label k=v or -Dname=value.  Each occurrence of the option
+// adds to the map, splitting each pair on the first '='.
+// (A pointer to a map with string keys can also be used directly
+// as ArgP; that behaves as a Map with no Separator and Unique false.)
+type Map struct {
+	// Target is a pointer to a map with string keys, for example
+	// *map[string]string or *map[string]int.  Values are converted
+	// using the same rules as for ArgP.  If the map is nil, it will
+	// be allocated.
+	Target interface{}
+
+	// Separator, if not empty, allows several pairs in a single
+	// argument.  For example, with "," the value "a=1,b=2" adds
+	// both a and b.
+	Separator string
+
+	// Unique causes a key that is given more than once to be
+	// reported as an error, rather than replacing the earlier value.
+	Unique bool
+}
+
+// cause strips the redundant function and input details from
+// strconv errors, since we report the option ourselves.
+func cause(e error) error {
+	if ne, ok := e.(*strconv.NumError); ok {
+		return ne.Err
+	}
+	return e
+}
+
+// secretError is an error about the value of a Secret option, with
+// the value left out of its message.
+type secretError struct {
+	err  error
+	vals []string
+}
+
+func (e *secretError) Error() string {
+	msg := e.err.Error()
+	for _, v := range e.vals {
+		if v != "" {
+			msg = strings.ReplaceAll(msg, v, redacted)
+		}
+	}
+	return msg
+}
+
+func (e *secretError) Unwrap() error {
+	return e.err
+}
+
+// store processes a value given for the option, running any
+// normalization, checking it against the choices, converting it
+// and validating it, and finally storing it in ArgP.  It returns the
+// value that should be passed to Handle.  For Secret options, the
+// value is left out of any error, unless it names the file holding
+// the secret.
+func (opt *Option) store(val string) (_ string, e error) {
+	if s, ok := opt.ArgP.(*Secret); opt.Secret && !(ok && s.FromFile) {
+		raw := val
+		defer func() {
+			if e != nil {
+				e = &secretError{err: e, vals: []string{raw, val}}
+			}
+		}()
+	}
+	if opt.Normalize != nil {
+		if val, e = opt.Normalize(val); e != nil {
+			return "", e
+		}
+	}
+	if len(opt.Choices) > 0 {
+		if val, e = opt.choose(val); e != nil {
+			return "", e
+		}
+	}
+	if opt.ArgP == nil {
+		if opt.Validate != nil {
+			e = opt.Validate(val)
+		}
+		return val, e
+	}
+	v, commit, e := convert(opt.ArgP, val, opt)
+	if e != nil {
+		return "", e
+	}
+	if opt.Validate != nil {
+		if e = opt.Validate(v); e != nil {
+			return "", e
+		}
+	}
+	commit()
+	return val, nil
+}
+
+// converter is implemented by the ArgP types in this package that
+// wrap a target.  Those cannot be converted by copying them, as that
+// would still store the value in the target.
+type converter interface {
+	convert(val string, opt *Option) (interface{}, func(), error)
+}
+
+// hinter is implemented by ArgP types that can describe the values
+// they expect, for help output.  It returns the default argument name
+// and a short description.
+type hinter interface {
+	hint() (string, string)
+}
+
+// valueHint returns the default argument name and description
+// for the values expected by p, if there are any.
+func valueHint(p interface{}) (string, string) {
+	switch v := p.(type) {
+	case hinter:
+		return v.hint()
+	case *time.Duration:
+		return "DURATION", "a duration such as 30s or 1h15m"
+	case *time.Time:
+		return (&Time{}).hint()
+	case *url.URL:
+		return (&URL{}).hint()
+	case *netip.Addr:
+		return "ADDR", ""
+	case *netip.AddrPort:
+		return "ADDR:PORT", ""
+	case *netip.Prefix:
+		return "CIDR", ""
+	case **regexp.Regexp:
+		return "REGEXP", ""
+	}
+	return "", ""
+}
+
+// convert converts val for storage where p points, but does not store
+// it.  It returns the converted value, and a function to store it.
+// The option supplies the policies for conversion, such as the base.
+func convert(p interface{}, val string, opt *Option) (interface{}, func(), error) {
+	if c, ok := p.(converter); ok {
+		return c.convert(val, opt)
+	}
+	if isStringMap(p) {
+		return (&Map{Target: p}).convert(val, opt)
+	}
+	if _, ok := p.(encoding.TextUnmarshaler); !ok && isSlice(p) {
+		return convertSlice(p, val, opt)
+	}
+	if v, ok := p.(*big.Int); ok {
+		// These cannot be copied, so need special care.
+		n, e := parseBigInt(val, opt.Base)
+		if e != nil {
+			return nil, nil, e
+		}
+		return n, func() { v.Set(n) }, nil
+	}
+	pv := reflect.ValueOf(p)
+	if pv.Kind() != reflect.Ptr {
+		// Probably a TextUnmarshaler with a value receiver.
+		// We have no way to avoid storing it directly.
+		return p, func() {}, setValue(p, val, opt.Base)
+	}
+	// Start with the current value, as some TextUnmarshalers
+	// accumulate values.
+	tmp := reflect.New(pv.Type().Elem())
+	tmp.Elem().Set(pv.Elem())
+	if e := setValue(tmp.Interface(), val, opt.Base); e != nil {
+		return nil, nil, e
+	}
+	return tmp.Elem().Interface(), func() { pv.Elem().Set(tmp.Elem()) }, nil
+}
+
+// settableTypes are the types, other than TextUnmarshalers, that
+// setValue can store.  This must be kept in step with setValue.
+var settableTypes = map[reflect.Type]bool{
+	reflect.TypeOf((*bool)(nil)):           true,
+	reflect.TypeOf((*string)(nil)):         true,
+	reflect.TypeOf((*int)(nil)):            true,
+	reflect.TypeOf((*int8)(nil)):           true,
+	reflect.TypeOf((*int16)(nil)):          true,
+	reflect.TypeOf((*int32)(nil)):          true,
+	reflect.TypeOf((*int64)(nil)):          true,
+	reflect.TypeOf((*uint)(nil)):           true,
+	reflect.TypeOf((*uint8)(nil)):          true,
+	reflect.TypeOf((*uint16)(nil)):         true,
+	reflect.TypeOf((*uint32)(nil)):         true,
+	reflect.TypeOf((*uint64)(nil)):         true,
+	reflect.TypeOf((*float32)(nil)):        true,
+	reflect.TypeOf((*float64)(nil)):        true,
+	reflect.TypeOf((*big.Int)(nil)):        true,
+	reflect.TypeOf((*time.Duration)(nil)):  true,
+	reflect.TypeOf((*time.Time)(nil)):      true,
+	reflect.TypeOf((*url.URL)(nil)):        true,
+	reflect.TypeOf((**regexp.Regexp)(nil)): true,
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// settable returns true if setValue can store values where a pointer
+// of type t points.
+func settable(t reflect.Type) bool {
+	return settableTypes[t] || t.Implements(textUnmarshalerType)
+}
+
+// checkArgP checks that p is something that values can be stored in.
+func checkArgP(p interface{}) error {
+	pv := reflect.ValueOf(p)
+	if pv.Kind() == reflect.Ptr && pv.IsNil() {
+		return fmt.Errorf("ArgP is a nil %T", p)
+	}
+	switch v := p.(type) {
+	case *Map:
+		if !isStringMap(v.Target) || reflect.ValueOf(v.Target).IsNil() {
+			return fmt.Errorf("map target %T is not a pointer to a map "+
+				"with string keys", v.Target)
+		}
+		p = v.Target
+	case *List:
+		if !isSlice(v.Target) || reflect.ValueOf(v.Target).IsNil() {
+			return fmt.Errorf("list target %T is not a pointer to a slice",
+				v.Target)
+		}
+		p = v.Target
+	case converter:
+		// The other wrappers in this package all have a Target.
+		if t := pv.Elem().FieldByName("Target"); t.IsValid() && t.IsNil() {
+			return fmt.Errorf("%T has a nil Target", p)
+		}
+		return nil
+	}
+	t := reflect.TypeOf(p)
+	switch {
+	case t.Implements(textUnmarshalerType):
+		return nil
+	case isStringMap(p), isSlice(p):
+		// The elements are stored with setValue.
+		if !settable(reflect.PtrTo(t.Elem().Elem())) {
+			return fmt.Errorf("cannot store values of type %v", t.Elem().Elem())
+		}
+		return nil
+	case t.Kind() != reflect.Ptr || !settable(t):
+		return fmt.Errorf("cannot store values in %T", p)
+	}
+	return nil
+}
+
+// setValue converts val and stores it where p points.  The base is
+// used for integers, as described for Option.Base.
+// Types that are not understood are silently ignored.
+func setValue(p interface{}, val string, base int) error {
+	var e error
+	switch v := p.(type) {
+	case *bool:
+		// we get 1, 0, true, false variants,
+		// but not yes and no. We want them.
+		switch val {
+		case "y", "Y", "YES", "yes":
+			val = "true"
+		case "n", "N", "NO", "no":
+			val = "false"
+		}
+		*v, e = strconv.ParseBool(val)
+	case *string:
+		*v = val
+	case *int, *int8, *int16, *int32, *int64:
+		var i int64
+		rv := reflect.ValueOf(p).Elem()
+		if i, e = parseInt(val, base, rv.Type().Bits()); e == nil {
+			rv.SetInt(i)
+		}
+	case *uint, *uint8, *uint16, *uint32, *uint64:
+		var u uint64
+		rv := reflect.ValueOf(p).Elem()
+		if u, e = parseUint(val, base, rv.Type().Bits()); e == nil {
+			rv.SetUint(u)
+		}
+	case *float32:
+		var f float64
+		if f, e = parseFloat(val, 32); e == nil {
+			*v = float32(f)
+		}
+	case *float64:
+		*v, e = parseFloat(val, 64)
+	case *big.Int:
+		var n *big.Int
+		if n, e = parseBigInt(val, base); e == nil {
+			v.Set(n)
+		}
+	case *time.Duration:
+		*v, e = time.ParseDuration(val)
+	case *time.Time:
+		*v, e = (&Time{}).parse(val)
+	case *url.URL:
+		var u *url.URL
+		if u, e = (&URL{}).parse(val); e == nil {
+			*v = *u
+		}
+	case **regexp.Regexp:
+		*v, e = compileRegexp(val)
+	case encoding.TextUnmarshaler:
+		e = v.UnmarshalText([]byte(val))
+	}
+	return e
+}
+
+func (m *Map) String() string {
+	return formatValue(m.Target)
+}
+
+func isStringMap(p interface{}) bool {
+	t := reflect.TypeOf(p)
+	return t != nil && t.Kind() == reflect.Ptr &&
+		t.Elem().Kind() == reflect.Map &&
+		t.Elem().Key().Kind() == reflect.String
+}
+
+func isSlice(p interface{}) bool {
+	t := reflect.TypeOf(p)
+	return t != nil && t.Kind() == reflect.Ptr &&
+		t.Elem().Kind() == reflect.Slice
+}
+
+// convertSlice converts a value to be appended to a slice.
+// Validate is passed just the new element.
+func convertSlice(p interface{}, val string, opt *Option) (interface{}, func(), error) {
+	sv := reflect.ValueOf(p).Elem()
+	elem := reflect.New(sv.Type().Elem())
+	if e := setValue(elem.Interface(), val, opt.Base); e != nil {
+		return nil, nil, e
+	}
+	commit := func() {
+		if opt.Repeat == RepeatLast {
+			sv.Set(reflect.MakeSlice(sv.Type(), 0, 1))
+		}
+		sv.Set(reflect.Append(sv, elem.Elem()))
+	}
+	return elem.Elem().Interface(), commit, nil
+}
+
+func (m *Map) convert(val string, opt *Option) (interface{}, func(), error) {
+	replace := opt.Repeat == RepeatLast
+	if !isStringMap(m.Target) {
+		return nil, nil, fmt.Errorf(
+			"map target %T is not a map with string keys", m.Target)
+	}
+	mv := reflect.ValueOf(m.Target).Elem()
+	pairs := []string{val}
+	if m.Separator != "" {
+		pairs = strings.Split(val, m.Separator)
+	}
+	add := reflect.MakeMap(mv.Type())
+	for _, pair := range pairs {
+		words := strings.SplitN(pair, "=", 2)
+		if len(words) != 2 {
+			return nil, nil, fmt.Errorf("missing '=' in %q", pair)
+		}
+		if words[0] == "" {
+			return nil, nil, fmt.Errorf("empty key in %q", pair)
+		}
+		key := reflect.ValueOf(words[0]).Convert(mv.Type().Key())
+		if m.Unique && (add.MapIndex(key).IsValid() ||
+			(!replace && !mv.IsNil() && mv.MapIndex(key).IsValid())) {
+			return nil, nil, fmt.Errorf("duplicate key %q", words[0])
+		}
+		elem := reflect.New(mv.Type().Elem())
+		if e := setValue(elem.Interface(), words[1], opt.Base); e != nil {
+			return nil, nil, fmt.Errorf("key %q: %v", words[0], cause(e))
+		}
+		add.SetMapIndex(key, elem.Elem())
+	}
+	commit := func() {
+		if mv.IsNil() || replace {
+			mv.Set(reflect.MakeMap(mv.Type()))
+		}
+		for _, key := range add.MapKeys() {
+			mv.SetMapIndex(key, add.MapIndex(key))
+		}
+	}
+	return add.Interface(), commit, nil
+}
+
+// formatValue returns the value p points to as a string.
+func formatValue(p interface{}) string {
+	switch v := p.(type) {
+	case encoding.TextMarshaler:
+		if b, e := v.MarshalText(); e == nil {
+			return string(b)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+	pv := reflect.ValueOf(p)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		return fmt.Sprint(p)
+	}
+	pv = pv.Elem()
+	if pv.Kind() == reflect.Slice {
+		var vals []string
+		for i := 0; i < pv.Len(); i++ {
+			vals = append(vals, fmt.Sprint(pv.Index(i).Interface()))
+		}
+		return strings.Join(vals, ",")
+	}
+	if pv.Kind() != reflect.Map {
+		return fmt.Sprint(pv.Interface())
+	}
+	var pairs []string
+	for _, k := range pv.MapKeys() {
+		pairs = append(pairs,
+			fmt.Sprintf("%v=%v", k.Interface(), pv.MapIndex(k).Interface()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
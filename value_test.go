@@ -0,0 +1,131 @@
+// Copyright 2019 Garrett D'Amore <garrett@damore.org>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package optopia
+
+import (
+	"testing"
+)
+
+func TestMap_Strings(t *testing.T) {
+	opts := &Options{}
+	var val map[string]string
+	o := &Option{
+		Short: 'D',
+		Long:  "define",
+		ArgP:  &val,
+	}
+	mustAdd(t, opts, o)
+
+	args := mustParse(t, opts, []string{"-Dname=value", "--define", "a=b=c",
+		"--define=empty=", "extra"})
+	if len(args) != 1 || args[0] != "extra" {
+		t.Fatal("oops")
+	}
+	if len(val) != 3 || val["name"] != "value" || val["a"] != "b=c" {
+		t.Errorf("wrong map contents: %v", val)
+	}
+	if v, ok := val["empty"]; !ok || v != "" {
+		t.Errorf("empty value missing")
+	}
+
+	// Later values replace earlier ones.
+	opts.Reset()
+	val = nil
+	_ = mustParse(t, opts, []string{"-Dname=value", "-Dname=other"})
+	if len(val) != 1 || val["name"] != "other" {
+		t.Errorf("did not replace value: %v", val)
+	}
+
+	// Reset leaves the map as it is, so values are added to it.
+	opts.Reset()
+	_ = mustParse(t, opts, []string{"-Da=b"})
+	if len(val) != 2 || val["name"] != "other" || val["a"] != "b" {
+		t.Errorf("wrong map contents: %v", val)
+	}
+
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-Dnovalue"})
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-D=value"})
+}
+
+func TestMap_Typed(t *testing.T) {
+	opts := &Options{}
+	var val map[string]int
+	o := &Option{
+		Long: "limit",
+		ArgP: &Map{Target: &val, Separator: ",", Unique: true},
+	}
+	mustAdd(t, opts, o)
+
+	_ = mustParse(t, opts, []string{"--limit", "a=1,b=2", "--limit", "c=3"})
+	if len(val) != 3 || val["a"] != 1 || val["b"] != 2 || val["c"] != 3 {
+		t.Errorf("wrong map contents: %v", val)
+	}
+
+	opts.Reset()
+	val = nil
+	_, e := opts.Parse([]string{"--limit", "a=1", "--limit", "a=2"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: --limit: duplicate key "a"` {
+		t.Errorf("wrong message: %v", e)
+	}
+
+	opts.Reset()
+	val = nil
+	_, e = opts.Parse([]string{"--limit", "a=1,b=x"})
+	mustFailAs(t, e, ErrParsingValue)
+	if e.Error() != `failure parsing option value: --limit: key "b": invalid syntax` {
+		t.Errorf("wrong message: %v", e)
+	}
+}
+
+func TestMap_BadTarget(t *testing.T) {
+	opts := &Options{}
+	var val []string
+	mustFailAs(t, opts.Add(&Option{Long: "bad", ArgP: &Map{Target: &val}}), ErrBadOption)
+}
+
+func TestSlice(t *testing.T) {
+	opts := &Options{}
+	var val []string
+	var seen []interface{}
+	o := &Option{
+		Short: 'I',
+		ArgP:  &val,
+		Validate: func(v interface{}) error {
+			seen = append(seen, v)
+			return nil
+		},
+	}
+	mustAdd(t, opts, o)
+	_ = mustParse(t, opts, []string{"-Ia", "-I", "b"})
+	if len(val) != 2 || val[0] != "a" || val[1] != "b" {
+		t.Errorf("wrong slice: %v", val)
+	}
+	if len(seen) != 2 || seen[1] != "b" {
+		t.Errorf("validate got wrong values: %v", seen)
+	}
+	if formatValue(&val) != "a,b" {
+		t.Errorf("wrong format: %s", formatValue(&val))
+	}
+
+	var nums []uint64
+	o.ArgP = &nums
+	opts.Reset()
+	mustNotParse(t, opts, []string{"-I", "-1"})
+}